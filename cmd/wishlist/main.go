@@ -1,17 +1,31 @@
 package main
 
 import (
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/rs/cors"
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"wish_list/internal/config"
-	"wish_list/internal/http-server/handlers/sharelist"
-	"wish_list/internal/http-server/handlers/wishlist"
-	"wish_list/internal/http-server/handlers/wishlist/item"
-	"wish_list/internal/http-server/middleware/logger"
+	"wish_list/internal/enricher"
+	"wish_list/internal/http-server/handlers/auth/uidextractor"
+	"wish_list/internal/http-server/router"
+	"wish_list/internal/lib/affiliate"
+	"wish_list/internal/lib/guest"
+	"wish_list/internal/lib/keyset"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/metadata"
+	"wish_list/internal/lib/metadata/marketplace"
+	"wish_list/internal/lib/oidc"
+	"wish_list/internal/lib/session"
+	"wish_list/internal/linkchecker"
+	"wish_list/internal/pricetracker"
+	"wish_list/internal/storage"
+	"wish_list/internal/storage/memory"
 	"wish_list/internal/storage/postgres"
 )
 
@@ -20,6 +34,11 @@ const (
 	envProd = "prod"
 )
 
+const (
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
+)
+
 func main() {
 	cfg := config.MustLoad()
 
@@ -28,63 +47,236 @@ func main() {
 	log.Info("App started", slog.String("env", cfg.Env))
 	log.Debug("Debugging started")
 
-	storage, err := postgres.New(
-		cfg.Postgres.Host,
-		cfg.Postgres.Port,
-		cfg.Postgres.User,
-		cfg.Postgres.Password,
-		cfg.Postgres.DBName,
-	)
+	storage, err := newStorage(cfg)
 	if err != nil {
-		log.Error("failed to init storage: ", err)
+		log.Error("failed to init storage", sl.Err(err))
 		os.Exit(1)
 	}
 
 	log.Info("storage successfully initialized")
 
-	router := chi.NewRouter()
+	keys, err := keyset.Load(cfg.Auth)
+	if err != nil {
+		log.Error("failed to load auth keys", sl.Err(err))
+		os.Exit(1)
+	}
+
+	if _, ok := keys.Secret(cfg.Auth.SigningKid); !ok {
+		log.Error("failed to load auth keys", sl.Err(uidextractor.ErrNoSigningKey), slog.String("signing_kid", cfg.Auth.SigningKid))
+		os.Exit(1)
+	}
+
+	log.Info("auth keys loaded", slog.Int("count", keys.Len()))
+
+	links, err := affiliate.Load(cfg.Affiliate)
+	if err != nil {
+		log.Error("failed to load affiliate rules", sl.Err(err))
+		os.Exit(1)
+	}
+
+	log.Info("affiliate rules loaded", slog.Int("count", links.Len()))
+
+	go reloadOnSignal(log, keys, links)
 
-	corsHandler := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "PUT", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
+	validator := uidextractor.New(keys, uidextractor.Options{
+		Issuer:              cfg.Auth.Issuer,
+		Audience:            cfg.Auth.Audience,
+		Leeway:              cfg.Auth.Leeway,
+		Denylist:            storage,
+		AcceptMissingExpiry: cfg.Auth.AcceptMissingExp,
 	})
 
-	router.Use(middleware.RequestID)
-	router.Use(middleware.Logger)
-	router.Use(logger.New(log))
-	router.Use(middleware.Recoverer)
-	router.Use(middleware.URLFormat)
-	router.Use(corsHandler.Handler)
-
-	router.Post("/api/wishlist/create", wishlist.Create(log, storage))             //создание вишлиста в личном кабинете
-	router.Post("/api/items/add", item.Create(log, storage))                       // добавление подарка в вишлист из ЛК
-	router.Get("/api/sharelist/{alias}", sharelist.GetList(log, storage))          // получение вишлиста по алиасу
-	router.Get("/api/wishlist/getforuser", wishlist.GetAllLists(log, storage))     // получение списка вишлистов пользователя в ЛК
-	router.Post("/api/wishlist/delete", wishlist.Delete(log, storage))             // удаление конкретного вишлиста в ЛК
-	router.Get("/api/wishlist/{wishlistId}/items", item.GetByWishId(log, storage)) // получение списка подарков конкретного вишлиста в ЛК
-	router.Post("/api/item/delete", item.Delete(log, storage))                     // удаление подарка из вишлиста в ЛК
+	issuer := uidextractor.NewIssuer(keys, uidextractor.IssuerOptions{
+		Kid:      cfg.Auth.SigningKid,
+		Issuer:   cfg.Auth.Issuer,
+		Audience: cfg.Auth.Audience,
+		TTL:      cfg.Auth.AccessTTL,
+	})
+
+	sessions := session.New(storage, issuer, cfg.Auth.RefreshTTL)
+
+	routerOpts := router.Options{
+		Storage:   storage,
+		Validator: validator,
+		Sessions:  sessions,
+		Guests:    guest.New(keys, cfg.Auth.SigningKid, cfg.Auth.Issuer, cfg.Auth.GuestTTL),
+		Links:     links,
+	}
+
+	// ctx is cancelled on SIGINT or SIGTERM and stops the background workers
+	// and the server.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go cleanupExpiredTokens(ctx, log, storage, cfg.Auth.CleanupInterval)
+
+	fetcher := metadata.NewFetcher(metadata.Options{
+		Timeout:  cfg.Enricher.Timeout,
+		MaxBytes: cfg.Enricher.MaxBytes,
+		Parsers:  marketplace.Default(),
+	})
+
+	if cfg.Enricher.Enabled {
+		e := enricher.New(log, fetcher, storage, enricher.Options{
+			Workers:   cfg.Enricher.Workers,
+			QueueSize: cfg.Enricher.QueueSize,
+		})
+
+		go e.Run(ctx)
+
+		log.Info("item enrichment enabled", slog.Int("workers", cfg.Enricher.Workers))
+
+		routerOpts.Enricher = e
+	}
+
+	if cfg.Prices.Enabled {
+		tracker := pricetracker.New(log, fetcher, storage, pricetracker.LogNotifier{Log: log}, pricetracker.Options{
+			Interval:  cfg.Prices.Interval,
+			Poll:      cfg.Prices.Poll,
+			BatchSize: cfg.Prices.BatchSize,
+		})
+
+		go tracker.Run(ctx)
+
+		log.Info("price tracking enabled", slog.Duration("interval", cfg.Prices.Interval))
+	}
+
+	if cfg.Links.Enabled {
+		prober := metadata.NewFetcher(metadata.Options{Timeout: cfg.Links.Timeout})
+
+		checker := linkchecker.New(log, prober, storage, linkchecker.Options{
+			Interval:     cfg.Links.Interval,
+			Poll:         cfg.Links.Poll,
+			BatchSize:    cfg.Links.BatchSize,
+			Workers:      cfg.Links.Workers,
+			HostInterval: cfg.Links.HostInterval,
+			Timeout:      cfg.Links.Timeout,
+		})
+
+		go checker.Run(ctx)
+
+		log.Info("link checking enabled", slog.Duration("interval", cfg.Links.Interval))
+	}
+
+	if cfg.OIDC.Enabled {
+		provider, err := oidc.Discover(context.Background(), &http.Client{Timeout: 10 * time.Second}, oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+			StateTTL:     cfg.OIDC.StateTTL,
+			Leeway:       cfg.Auth.Leeway,
+		})
+		if err != nil {
+			log.Error("failed to init oidc provider", sl.Err(err))
+			os.Exit(1)
+		}
+
+		log.Info("oidc login enabled", slog.String("issuer", cfg.OIDC.Issuer))
+
+		routerOpts.OIDC = provider
+		routerOpts.OIDCName = cfg.OIDC.Provider
+	}
 
 	log.Info("starting server", slog.String("address", cfg.Address))
 
 	srv := &http.Server{
 		Addr:         cfg.Address,
-		Handler:      router,
+		Handler:      router.New(log, routerOpts),
 		ReadTimeout:  cfg.HTTPServer.Timeout,
 		WriteTimeout: cfg.HTTPServer.Timeout,
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Error("failed to start server")
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.Timeout)
+		defer cancel()
+
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error("failed to shut down server", sl.Err(err))
+		}
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("failed to start server", sl.Err(err))
 	}
 
 	log.Error("server stopped")
 }
 
+// newStorage opens the backend selected by cfg.Storage.
+func newStorage(cfg *config.Config) (storage.Store, error) {
+	switch cfg.Storage {
+	case storageMemory:
+		return memory.New(), nil
+	case storagePostgres:
+		return postgres.New(
+			cfg.Postgres.Host,
+			cfg.Postgres.Port,
+			cfg.Postgres.User,
+			cfg.Postgres.Password,
+			cfg.Postgres.DBName,
+			cfg.Postgres.QueryTimeout,
+		)
+	}
+
+	return nil, fmt.Errorf("unknown storage %q", cfg.Storage)
+}
+
+// reloadOnSignal re-reads the config file and swaps the verification keys
+// and affiliate rules every time the process receives SIGHUP, so secrets can
+// be rotated and rules changed without a restart.
+func reloadOnSignal(log *slog.Logger, keys *keyset.Set, links *affiliate.Rewriter) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	for range sighup {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Error("failed to reload config", sl.Err(err))
+			continue
+		}
+
+		if err := keys.Reload(cfg.Auth); err != nil {
+			log.Error("failed to reload auth keys", sl.Err(err))
+		} else {
+			log.Info("auth keys reloaded", slog.Int("count", keys.Len()))
+		}
+
+		if err := links.Reload(cfg.Affiliate); err != nil {
+			log.Error("failed to reload affiliate rules", sl.Err(err))
+		} else {
+			log.Info("affiliate rules reloaded", slog.Int("count", links.Len()))
+		}
+	}
+}
+
+// cleanupExpiredTokens periodically purges denylist entries and refresh
+// tokens past their expiry until ctx is cancelled.
+func cleanupExpiredTokens(ctx context.Context, log *slog.Logger, storage storage.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := storage.DeleteExpiredTokens(ctx)
+		if err != nil {
+			log.Error("failed to delete expired tokens", sl.Err(err))
+			continue
+		}
+
+		log.Debug("expired tokens deleted", slog.Int64("count", n))
+	}
+}
+
 func SetupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
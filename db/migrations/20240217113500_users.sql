@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS wish_users (
+    uid SERIAL PRIMARY KEY
+);
+
+-- Users created before accounts had logins keep an empty password hash,
+-- which no password matches.
+ALTER TABLE wish_users
+    ADD COLUMN IF NOT EXISTS login VARCHAR UNIQUE,
+    ADD COLUMN IF NOT EXISTS password_hash VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE wish_users
+    DROP COLUMN IF EXISTS created_at,
+    DROP COLUMN IF EXISTS password_hash,
+    DROP COLUMN IF EXISTS login;
+-- +goose StatementEnd
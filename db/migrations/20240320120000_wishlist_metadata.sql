@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE wishlist
+    ADD COLUMN IF NOT EXISTS description VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS event_date DATE,
+    ADD COLUMN IF NOT EXISTS cover_emoji VARCHAR NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS cover_color VARCHAR NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE wishlist
+    DROP COLUMN IF EXISTS cover_color,
+    DROP COLUMN IF EXISTS cover_emoji,
+    DROP COLUMN IF EXISTS event_date,
+    DROP COLUMN IF EXISTS description;
+-- +goose StatementEnd
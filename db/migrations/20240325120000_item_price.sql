@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS price BIGINT,
+    ADD COLUMN IF NOT EXISTS currency CHAR(3),
+    ADD CONSTRAINT items_price_check CHECK (price IS NULL OR price >= 0),
+    ADD CONSTRAINT items_price_currency_check CHECK ((price IS NULL) = (currency IS NULL));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE items
+    DROP CONSTRAINT IF EXISTS items_price_currency_check,
+    DROP CONSTRAINT IF EXISTS items_price_check,
+    DROP COLUMN IF EXISTS currency,
+    DROP COLUMN IF EXISTS price;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE wishlist ADD COLUMN IF NOT EXISTS surprise BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE IF NOT EXISTS reservations (
+    reservation_id SERIAL PRIMARY KEY,
+    gift_id INT NOT NULL UNIQUE REFERENCES items(gift_id) ON DELETE CASCADE,
+    reserver_uid INT REFERENCES wish_users(uid) ON DELETE CASCADE,
+    guest_id VARCHAR,
+    name VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (reserver_uid IS NOT NULL OR guest_id IS NOT NULL)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS reservations;
+ALTER TABLE wishlist DROP COLUMN IF EXISTS surprise;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items ADD COLUMN IF NOT EXISTS funded_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS contributions (
+    contribution_id SERIAL PRIMARY KEY,
+    gift_id INT NOT NULL REFERENCES items(gift_id) ON DELETE CASCADE,
+    contributor_uid INT REFERENCES wish_users(uid) ON DELETE CASCADE,
+    guest_id VARCHAR,
+    name VARCHAR NOT NULL DEFAULT '',
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (contributor_uid IS NOT NULL OR guest_id IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS contributions_gift_id_idx ON contributions (gift_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS contributions;
+ALTER TABLE items DROP COLUMN IF EXISTS funded_at;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0);
+
+ALTER TABLE reservations
+    DROP CONSTRAINT IF EXISTS reservations_gift_id_key,
+    ADD COLUMN IF NOT EXISTS quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0);
+
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_gift_uid_idx
+    ON reservations (gift_id, reserver_uid) WHERE reserver_uid IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_gift_guest_idx
+    ON reservations (gift_id, guest_id) WHERE guest_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS reservations_gift_guest_idx;
+DROP INDEX IF EXISTS reservations_gift_uid_idx;
+DELETE FROM reservations a USING reservations b
+    WHERE a.gift_id = b.gift_id AND a.reservation_id > b.reservation_id;
+ALTER TABLE reservations
+    DROP COLUMN IF EXISTS quantity,
+    ADD CONSTRAINT reservations_gift_id_key UNIQUE (gift_id);
+ALTER TABLE items DROP COLUMN IF EXISTS quantity;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS priority VARCHAR NOT NULL DEFAULT 'nice_to_have'
+        CHECK (priority IN ('must_have', 'nice_to_have')),
+    ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+
+UPDATE items SET position = ordered.position
+FROM (
+    SELECT gift_id, row_number() OVER (PARTITION BY wishlist_id ORDER BY gift_id) AS position
+    FROM items
+) AS ordered
+WHERE items.gift_id = ordered.gift_id;
+
+CREATE INDEX IF NOT EXISTS items_wishlist_position_idx ON items (wishlist_id, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS items_wishlist_position_idx;
+ALTER TABLE items
+    DROP COLUMN IF EXISTS position,
+    DROP COLUMN IF EXISTS priority;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS image_url TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS options JSONB NOT NULL DEFAULT '{}'
+        CHECK (jsonb_typeof(options) = 'object');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE items
+    DROP COLUMN IF EXISTS options,
+    DROP COLUMN IF EXISTS image_url,
+    DROP COLUMN IF EXISTS description;
+-- +goose StatementEnd
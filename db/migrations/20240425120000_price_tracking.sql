@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS price_alert BIGINT CHECK (price_alert >= 0),
+    ADD COLUMN IF NOT EXISTS price_alert_currency CHAR(3),
+    ADD COLUMN IF NOT EXISTS price_checked_at TIMESTAMPTZ,
+    ADD CONSTRAINT items_price_alert_currency_check
+        CHECK ((price_alert IS NULL) = (price_alert_currency IS NULL));
+
+CREATE TABLE IF NOT EXISTS price_history (
+    id BIGSERIAL PRIMARY KEY,
+    gift_id INT NOT NULL REFERENCES items(gift_id) ON DELETE CASCADE,
+    price BIGINT NOT NULL CHECK (price >= 0),
+    currency CHAR(3) NOT NULL,
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS price_history_gift_id_idx ON price_history (gift_id, checked_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS price_history;
+ALTER TABLE items
+    DROP CONSTRAINT IF EXISTS items_price_alert_currency_check,
+    DROP COLUMN IF EXISTS price_checked_at,
+    DROP COLUMN IF EXISTS price_alert_currency,
+    DROP COLUMN IF EXISTS price_alert;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS link_status VARCHAR
+        CHECK (link_status IN ('ok', 'redirect', 'not_found', 'timeout', 'blocked', 'error')),
+    ADD COLUMN IF NOT EXISTS link_checked_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE items
+    DROP COLUMN IF EXISTS link_checked_at,
+    DROP COLUMN IF EXISTS link_status;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE wish_users ALTER COLUMN login DROP NOT NULL;
+UPDATE wish_users SET login = NULL
+    WHERE password_hash = '' AND uid IN (SELECT uid FROM user_identities);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE wish_users SET login = 'external:' || uid WHERE login IS NULL;
+ALTER TABLE wish_users ALTER COLUMN login SET NOT NULL;
+-- +goose StatementEnd
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 	"log"
@@ -10,8 +11,15 @@ import (
 
 type Config struct {
 	Env        string `yaml:"env" env-default:"local"`
+	Storage    string `yaml:"storage" env:"STORAGE" env-default:"postgres"`
 	HTTPServer `yaml:"http_server"`
 	Postgres   `yaml:"postgres"`
+	Auth       `yaml:"auth"`
+	OIDC       `yaml:"oidc"`
+	Enricher   `yaml:"enricher"`
+	Prices     `yaml:"price_tracker"`
+	Links      `yaml:"link_checker"`
+	Affiliate  `yaml:"affiliate"`
 }
 
 type HTTPServer struct {
@@ -25,6 +33,99 @@ type Postgres struct {
 	User     string `yaml:"user" env-default:"postgres"`
 	Password string `yaml:"password" env-default:"postgres"`
 	DBName   string `yaml:"db_name" env-default:"postgres"`
+	// QueryTimeout bounds every storage call on top of the request context.
+	QueryTimeout time.Duration `yaml:"query_timeout" env-default:"3s"`
+}
+
+// Auth lists the keys accepted when verifying JWTs. Shared secrets are
+// declared in Keys, asymmetric public keys are read from JWKSFile.
+// Issuer and Audience are enforced only when set. Tokens issued by the
+// service itself are signed with the HMAC key named by SigningKid.
+type Auth struct {
+	Keys       []Key         `yaml:"keys"`
+	JWKSFile   string        `yaml:"jwks_file" env:"AUTH_JWKS_FILE"`
+	Issuer     string        `yaml:"issuer" env:"AUTH_ISSUER"`
+	Audience   string        `yaml:"audience" env:"AUTH_AUDIENCE"`
+	Leeway     time.Duration `yaml:"leeway" env-default:"30s"`
+	SigningKid string        `yaml:"signing_kid"`
+	AccessTTL  time.Duration `yaml:"access_ttl" env-default:"15m"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env-default:"720h"`
+	GuestTTL   time.Duration `yaml:"guest_ttl" env-default:"2160h"`
+	// CleanupInterval is how often expired revocations are purged.
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env-default:"1h"`
+	// AcceptMissingExp accepts tokens without an exp claim. It only exists
+	// for the switch to mandatory exp and should stay off otherwise.
+	AcceptMissingExp bool `yaml:"accept_missing_exp" env:"AUTH_ACCEPT_MISSING_EXP"`
+}
+
+// Key is an HMAC secret. Exactly one of Secret, SecretEnv or SecretFile
+// should be set; Kid must match the kid header of tokens signed with it.
+type Key struct {
+	Kid        string `yaml:"kid"`
+	Alg        string `yaml:"alg"`
+	Secret     string `yaml:"secret"`
+	SecretEnv  string `yaml:"secret_env"`
+	SecretFile string `yaml:"secret_file"`
+}
+
+// OIDC configures the optional login through an external OpenID provider.
+// Provider is the name stored alongside the subject of linked accounts.
+type OIDC struct {
+	Enabled      bool          `yaml:"enabled" env:"OIDC_ENABLED"`
+	Provider     string        `yaml:"provider" env-default:"oidc"`
+	Issuer       string        `yaml:"issuer" env:"OIDC_ISSUER"`
+	ClientID     string        `yaml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret string        `yaml:"client_secret" env:"OIDC_CLIENT_SECRET"`
+	RedirectURL  string        `yaml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes       []string      `yaml:"scopes"`
+	StateTTL     time.Duration `yaml:"state_ttl" env-default:"10m"`
+}
+
+// Enricher configures the background lookup of product details on the
+// pages of newly added items. Timeout and MaxBytes also bound the page
+// fetches of the price tracker.
+type Enricher struct {
+	Enabled   bool          `yaml:"enabled" env:"ENRICHER_ENABLED"`
+	Workers   int           `yaml:"workers" env-default:"4"`
+	QueueSize int           `yaml:"queue_size" env-default:"256"`
+	Timeout   time.Duration `yaml:"timeout" env-default:"5s"`
+	MaxBytes  int64         `yaml:"max_bytes" env-default:"2097152"`
+}
+
+// Prices configures the background re-checking of item prices.
+type Prices struct {
+	Enabled   bool          `yaml:"enabled" env:"PRICE_TRACKER_ENABLED"`
+	Interval  time.Duration `yaml:"interval" env-default:"6h"`
+	Poll      time.Duration `yaml:"poll" env-default:"1m"`
+	BatchSize int           `yaml:"batch_size" env-default:"50"`
+}
+
+// Links configures the background checking of item URLs. Workers bounds
+// the requests in flight and HostInterval spaces requests to one host.
+type Links struct {
+	Enabled      bool          `yaml:"enabled" env:"LINK_CHECKER_ENABLED"`
+	Interval     time.Duration `yaml:"interval" env-default:"24h"`
+	Poll         time.Duration `yaml:"poll" env-default:"1m"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	Workers      int           `yaml:"workers" env-default:"8"`
+	HostInterval time.Duration `yaml:"host_interval" env-default:"2s"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+// Affiliate lists the rules that turn outbound item links into affiliate
+// links when items are shown. Stored links never change. The rules are
+// re-read on SIGHUP together with the auth keys.
+type Affiliate struct {
+	Rules []AffiliateRule `yaml:"rules"`
+}
+
+// AffiliateRule applies to links on Host and its subdomains. Params are set
+// on the link's query string. Template, when not empty, wraps the result:
+// its {url} placeholder is replaced with the escaped link.
+type AffiliateRule struct {
+	Host     string            `yaml:"host"`
+	Params   map[string]string `yaml:"params"`
+	Template string            `yaml:"template"`
 }
 
 func MustLoad() *Config {
@@ -32,20 +133,30 @@ func MustLoad() *Config {
 		log.Printf("Warning: .env file not found or error loading it: %v", err)
 	}
 
+	cfg, err := Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return cfg
+}
+
+// Load reads the config file pointed to by CONFIG_PATH.
+func Load() (*Config, error) {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
-		log.Fatalf("CONFIG_PATH env var is not set")
+		return nil, fmt.Errorf("CONFIG_PATH env var is not set")
 	}
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatalf("CONFIG_PATH %s does not exist", configPath)
+		return nil, fmt.Errorf("CONFIG_PATH %s does not exist", configPath)
 	}
 
 	var cfg Config
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		log.Fatalf("failed to load config: %s", err)
+		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	return &cfg
+	return &cfg, nil
 }
@@ -0,0 +1,129 @@
+// Package enricher fills in item details from the item's web page in the
+// background, so adding an item never waits on a third-party site.
+package enricher
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/metadata"
+)
+
+// Fetcher reads product details from a page.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (metadata.Product, error)
+}
+
+// Store saves the details found; only fields the owner left empty change.
+type Store interface {
+	FillItem(ctx context.Context, itemId int, url string, meta entity.GiftMetadata) error
+}
+
+// Options tune an Enricher. Zero values fall back to the defaults.
+type Options struct {
+	Workers   int           // concurrent fetches; default 4
+	QueueSize int           // pending jobs before new ones are dropped; default 256
+	Timeout   time.Duration // per job, fetch and save included; default 15s
+}
+
+type job struct {
+	itemId int
+	url    string
+}
+
+// Enricher queues items and processes them with a fixed pool of workers
+// started by Run.
+type Enricher struct {
+	log     *slog.Logger
+	fetcher Fetcher
+	store   Store
+	jobs    chan job
+	workers int
+	timeout time.Duration
+}
+
+func New(log *slog.Logger, fetcher Fetcher, store Store, opts Options) *Enricher {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 256
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 15 * time.Second
+	}
+
+	return &Enricher{
+		log:     log.With(slog.String("component", "enricher")),
+		fetcher: fetcher,
+		store:   store,
+		jobs:    make(chan job, opts.QueueSize),
+		workers: opts.Workers,
+		timeout: opts.Timeout,
+	}
+}
+
+// Enqueue schedules an item for enrichment. It never blocks: when the queue
+// is full the job is dropped and false is returned.
+func (e *Enricher) Enqueue(itemId int, url string) bool {
+	select {
+	case e.jobs <- job{itemId: itemId, url: url}:
+		return true
+	default:
+		e.log.Warn("enrichment queue is full, job dropped", slog.Int("item_id", itemId))
+		return false
+	}
+}
+
+// Run processes queued jobs until ctx is done, then waits for the jobs in
+// progress to finish.
+func (e *Enricher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < e.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-e.jobs:
+					e.process(ctx, j)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (e *Enricher) process(ctx context.Context, j job) {
+	log := e.log.With(slog.Int("item_id", j.itemId))
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	p, err := e.fetcher.Fetch(ctx, j.url)
+	if err != nil {
+		log.Info("failed to fetch item page", sl.Err(err))
+		return
+	}
+
+	meta := entity.GiftMetadata{Name: p.Title, ImageUrl: p.ImageUrl, Price: p.Price}
+	if meta == (entity.GiftMetadata{}) {
+		log.Debug("no metadata found on item page")
+		return
+	}
+
+	if err := e.store.FillItem(ctx, j.itemId, j.url, meta); err != nil {
+		log.Error("failed to save item metadata", sl.Err(err))
+		return
+	}
+
+	log.Debug("item enriched")
+}
@@ -0,0 +1,97 @@
+package enricher_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"wish_list/internal/enricher"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/metadata"
+)
+
+type filled struct {
+	itemId int
+	url    string
+	meta   entity.GiftMetadata
+}
+
+// fakeStore reports every FillItem call on a channel.
+type fakeStore chan filled
+
+func (f fakeStore) FillItem(_ context.Context, itemId int, url string, meta entity.GiftMetadata) error {
+	f <- filled{itemId: itemId, url: url, meta: meta}
+	return nil
+}
+
+func TestEnricher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/product":
+			w.Write([]byte(`<html><head>
+<meta property="og:title" content="Board game">
+<meta property="og:image" content="https://cdn.example.com/game.jpg">
+<meta property="product:price:amount" content="39.99">
+<meta property="product:price:currency" content="EUR">
+</head></html>`))
+		case "/empty":
+			w.Write([]byte(`<html><body>nothing here</body></html>`))
+		}
+	}))
+	defer srv.Close()
+
+	store := make(fakeStore, 4)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e := enricher.New(log, metadata.NewFetcher(metadata.Options{AllowPrivate: true}), store, enricher.Options{Workers: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+
+	e.Enqueue(1, srv.URL+"/empty")
+	e.Enqueue(2, srv.URL+"/missing")
+	e.Enqueue(3, srv.URL+"/product")
+
+	select {
+	case got := <-store:
+		want := entity.GiftMetadata{
+			Name:     "Board game",
+			ImageUrl: "https://cdn.example.com/game.jpg",
+			Price:    &entity.Price{Amount: 3999, Currency: "EUR"},
+		}
+		if got.itemId != 3 || got.url != srv.URL+"/product" || got.meta.Name != want.Name ||
+			got.meta.ImageUrl != want.ImageUrl || got.meta.Price == nil || *got.meta.Price != *want.Price {
+			t.Fatalf("filled %+v, want item 3 with %+v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("item was not enriched")
+	}
+
+	cancel()
+	<-done
+
+	select {
+	case got := <-store:
+		t.Fatalf("unexpected fill %+v for a page without metadata", got)
+	default:
+	}
+}
+
+func TestEnqueueDropsWhenFull(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	e := enricher.New(log, metadata.NewFetcher(metadata.Options{}), make(fakeStore), enricher.Options{QueueSize: 1})
+
+	if !e.Enqueue(1, "https://example.com/a") {
+		t.Fatal("first job was dropped")
+	}
+	if e.Enqueue(2, "https://example.com/b") {
+		t.Fatal("job accepted beyond the queue size")
+	}
+}
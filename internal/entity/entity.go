@@ -1,16 +1,234 @@
 package entity
 
+import "time"
+
 type WishList struct {
-	WishListId string `json:"wish_list_id"`
-	Name       string `json:"name"`
-	UID        int    `json:"uid"`
-	Alias      string `json:"alias"`
+	WishListId  string `json:"wish_list_id"`
+	Name        string `json:"name"`
+	UID         int    `json:"uid"`
+	Alias       string `json:"alias"`
+	Description string `json:"description"`
+	EventDate   string `json:"event_date,omitempty"` // YYYY-MM-DD
+	CoverEmoji  string `json:"cover_emoji"`
+	CoverColor  string `json:"cover_color"`
+	Surprise    bool   `json:"surprise"` // hides reservations from the owner
+}
+
+// WishListUpdate lists the wishlist fields to change. Nil fields are left
+// as is; an empty EventDate clears the date.
+type WishListUpdate struct {
+	Name        *string
+	Description *string
+	EventDate   *string
+	CoverEmoji  *string
+	CoverColor  *string
+	Surprise    *bool
+}
+
+// Price is an amount in minor units of an ISO 4217 currency.
+type Price struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
 }
 
 type GiftList struct {
-	GiftId       int    `json:"gift_id"`
-	WishListId   int    `json:"wish_list_id"`
-	WishListName string `json:"wish_list_name"`
-	Name         string `json:"name"`
-	Url          string `json:"url"`
+	GiftId       int           `json:"gift_id"`
+	WishListId   int           `json:"wish_list_id"`
+	WishListName string        `json:"wish_list_name"`
+	Name         string        `json:"name"`
+	Url          string        `json:"url"`
+	SourceUrl    string        `json:"source_url,omitempty"` // owner only: the stored link when Url is its affiliate form
+	Clicks       int           `json:"clicks,omitempty"`     // owner only: outbound clicks through /go/{gift_id}
+	Description  string        `json:"description"`
+	ImageUrl     string        `json:"image_url"`
+	Options      Options       `json:"options"`
+	Price        *Price        `json:"price,omitempty"`
+	PriceAlert   *Price        `json:"price_alert,omitempty"` // owner only: notify when the tracked price drops below
+	Link         *LinkHealth   `json:"link,omitempty"`        // owner only: result of the latest URL check
+	Quantity     int           `json:"quantity"`
+	Priority     string        `json:"priority"`
+	Position     int           `json:"position"`
+	Remaining    *int          `json:"remaining,omitempty"` // unreserved units, when reservations are visible
+	Reservations []Reservation `json:"reservations,omitempty"`
+	Funding      *Funding      `json:"funding,omitempty"`
+}
+
+// Reserver identifies who reserves an item: either an account (UID) or a
+// guest holding a signed guest token (GuestId).
+type Reserver struct {
+	UID     int
+	GuestId string
+	Name    string
+}
+
+// Reservation claims Quantity units of an item. The reserver's identity
+// never leaves the server; Mine tells a guest the reservation is theirs.
+type Reservation struct {
+	Quantity    int       `json:"quantity"`
+	Name        string    `json:"name,omitempty"`
+	Mine        bool      `json:"mine"`
+	CreatedAt   time.Time `json:"created_at"`
+	ReserverUID int       `json:"-"`
+	GuestId     string    `json:"-"`
+}
+
+// Funding is the progress of a group gift: the sum pledged towards the
+// item price. FullyFunded is set once the pledges reach the target.
+type Funding struct {
+	Pledged       int64          `json:"pledged"`
+	Target        int64          `json:"target"`
+	Currency      string         `json:"currency"`
+	FullyFunded   bool           `json:"fully_funded"`
+	Contributions []Contribution `json:"contributions,omitempty"`
+}
+
+// Contribution is a pledge towards a group gift. Like Reservation, the
+// contributor's identity never leaves the server.
+type Contribution struct {
+	ContributionId int       `json:"contribution_id"`
+	Amount         int64     `json:"amount"`
+	Name           string    `json:"name,omitempty"`
+	Mine           bool      `json:"mine"`
+	CreatedAt      time.Time `json:"created_at"`
+	ContributorUID int       `json:"-"`
+	GuestId        string    `json:"-"`
+}
+
+// MadeBy reports whether the contribution was pledged by r.
+func (c *Contribution) MadeBy(r Reserver) bool {
+	if r.UID != 0 {
+		return c.ContributorUID == r.UID
+	}
+	return r.GuestId != "" && c.GuestId == r.GuestId
+}
+
+// HeldBy reports whether the reservation was made by r.
+func (res *Reservation) HeldBy(r Reserver) bool {
+	if r.UID != 0 {
+		return res.ReserverUID == r.UID
+	}
+	return r.GuestId != "" && res.GuestId == r.GuestId
+}
+
+// Options are free-form variant attributes of an item, such as size or
+// color, so a giver knows exactly which version to buy.
+type Options map[string]string
+
+// Item priorities.
+const (
+	PriorityMustHave   = "must_have"
+	PriorityNiceToHave = "nice_to_have"
+)
+
+// GiftCreate holds the fields of a new item.
+type GiftCreate struct {
+	Name        string
+	Url         string
+	Description string
+	ImageUrl    string
+	Options     Options
+	Price       *Price
+	Quantity    int
+	Priority    string
+}
+
+// GiftUpdate lists the item fields to change. Nil fields are left as is;
+// ClearPrice and ClearAlert remove the price and the price alert, empty
+// Options remove all options.
+type GiftUpdate struct {
+	Name        *string
+	Url         *string
+	Description *string
+	ImageUrl    *string
+	Options     *Options
+	Price       *Price
+	ClearPrice  bool
+	PriceAlert  *Price
+	ClearAlert  bool
+	Quantity    *int
+	Priority    *string
+}
+
+// GiftMetadata holds item details found on the item's web page. Empty
+// fields were not found.
+type GiftMetadata struct {
+	Name     string
+	ImageUrl string
+	Price    *Price
+}
+
+// PricePoint is a price observed on an item's page.
+type PricePoint struct {
+	Price
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// TrackedItem is an item whose page is re-fetched to follow its price.
+// LastPrice is the latest observation, if any.
+type TrackedItem struct {
+	GiftId     int
+	WishListId int
+	UID        int
+	Name       string
+	Url        string
+	LastPrice  *Price
+	PriceAlert *Price
+}
+
+// PriceDrop is the event emitted when a tracked price falls below the
+// owner's alert threshold.
+type PriceDrop struct {
+	GiftId     int
+	WishListId int
+	UID        int
+	Name       string
+	Url        string
+	Price      Price
+	Threshold  Price
+	Previous   *Price
+}
+
+// Link statuses recorded by the link checker.
+const (
+	LinkOK       = "ok"
+	LinkRedirect = "redirect"  // the page moved, often to a category or home page
+	LinkNotFound = "not_found" // 404 or 410
+	LinkTimeout  = "timeout"
+	LinkBlocked  = "blocked" // the shop refused automated requests
+	LinkError    = "error"   // any other failure status or network error
+)
+
+// LinkHealth is the result of the latest check of an item's URL. Broken
+// flags links the owner should fix before sharing the wishlist.
+type LinkHealth struct {
+	Status    string    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	Broken    bool      `json:"broken"`
+}
+
+// NewLinkHealth builds the health of a link checked at checkedAt.
+func NewLinkHealth(status string, checkedAt time.Time) *LinkHealth {
+	broken := status == LinkNotFound || status == LinkTimeout || status == LinkError
+
+	return &LinkHealth{Status: status, CheckedAt: checkedAt, Broken: broken}
+}
+
+// LinkItem is an item whose URL is checked by the link checker.
+type LinkItem struct {
+	GiftId int
+	Url    string
+}
+
+type User struct {
+	UID          int    `json:"uid"`
+	Login        string `json:"login"`
+	PasswordHash string `json:"-"`
+}
+
+type APIToken struct {
+	TokenId    int        `json:"token_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
 }
@@ -0,0 +1,209 @@
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/middleware/auth"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/session"
+	"wish_list/internal/storage"
+)
+
+const maxNameLen = 100
+
+type Request struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// Response carries the plain token. It is returned only once, on creation.
+type Response struct {
+	resp.Response
+	TokenId int      `json:"token_id"`
+	Name    string   `json:"name"`
+	Scopes  []string `json:"scopes"`
+	Token   string   `json:"token"`
+}
+
+type APIToken interface {
+	CreateAPIToken(ctx context.Context, uid int, name, tokenHash string, scopes []string) (int, error)
+	GetAPITokens(ctx context.Context, uid int) ([]entity.APIToken, error)
+	RevokeAPIToken(ctx context.Context, tokenId, uid int) error
+}
+
+func Create(log *slog.Logger, apiToken APIToken) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apitoken.Create"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
+			return
+		}
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || utf8.RuneCountInString(req.Name) > maxNameLen {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("name must be between 1 and 100 characters"))
+			return
+		}
+
+		if len(req.Scopes) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("at least one scope is required"))
+			return
+		}
+		for _, scope := range req.Scopes {
+			if !slices.Contains(auth.Scopes, scope) {
+				w.WriteHeader(http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("unknown scope "+scope))
+				return
+			}
+		}
+		slices.Sort(req.Scopes)
+		req.Scopes = slices.Compact(req.Scopes)
+
+		token, err := newToken()
+		if err != nil {
+			log.Error("failed to generate token", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		tokenId, err := apiToken.CreateAPIToken(r.Context(), uid, req.Name, session.HashToken(token), req.Scopes)
+		if err != nil {
+			log.Error("failed to create api token", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("api token created", slog.Int("token_id", tokenId))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			TokenId:  tokenId,
+			Name:     req.Name,
+			Scopes:   req.Scopes,
+			Token:    token,
+		})
+	}
+}
+
+func List(log *slog.Logger, apiToken APIToken) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apitoken.List"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
+			return
+		}
+
+		tokens, err := apiToken.GetAPITokens(r.Context(), uid)
+		if err != nil {
+			log.Error("failed to get api tokens", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		render.JSON(w, r, tokens)
+	}
+}
+
+func Revoke(log *slog.Logger, apiToken APIToken) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apitoken.Revoke"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
+			return
+		}
+
+		tokenId, err := strconv.Atoi(chi.URLParam(r, "tokenId"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid tokenId"))
+			return
+		}
+
+		err = apiToken.RevokeAPIToken(r.Context(), tokenId, uid)
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("token not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to revoke api token", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("api token revoked", slog.Int("token_id", tokenId))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return auth.APITokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
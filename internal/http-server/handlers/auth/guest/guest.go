@@ -0,0 +1,50 @@
+package guest
+
+import (
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"time"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+)
+
+// Response carries a token identifying a visitor without an account. It is
+// sent back in the X-Guest-Token header.
+type Response struct {
+	resp.Response
+	GuestToken string `json:"guest_token"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+type Issuer interface {
+	Issue() (string, string, time.Time, error)
+}
+
+func Token(log *slog.Logger, issuer Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.auth.guest.Token"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		token, _, exp, err := issuer.Issue()
+		if err != nil {
+			log.Error("failed to issue guest token", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("guest token issued")
+
+		render.JSON(w, r, Response{
+			Response:   resp.OK(),
+			GuestToken: token,
+			ExpiresIn:  int(time.Until(exp).Seconds()),
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package sso
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"wish_list/internal/http-server/handlers/auth/user"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/oidc"
+	"wish_list/internal/lib/session"
+)
+
+type Provider interface {
+	AuthURL() (string, error)
+	Exchange(ctx context.Context, state, code string) (oidc.Identity, error)
+}
+
+type Users interface {
+	GetOrCreateExternalUser(ctx context.Context, provider, subject, email string) (int, error)
+}
+
+type Sessions interface {
+	Start(ctx context.Context, uid int) (session.Tokens, error)
+}
+
+// Login redirects the browser to the identity provider.
+func Login(log *slog.Logger, provider Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.sso.Login"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		authURL, err := provider.AuthURL()
+		if err != nil {
+			log.Error("failed to build authorization url", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// Callback finishes the login, links the provider subject to a local uid
+// and returns the same token pair as a password login.
+func Callback(log *slog.Logger, providerName string, provider Provider, users Users, sessions Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.sso.Callback"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		q := r.URL.Query()
+
+		if e := q.Get("error"); e != "" {
+			log.Info("provider returned error", slog.String("error", e))
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.ErrorCode(e, "login was not completed"))
+			return
+		}
+
+		state, code := q.Get("state"), q.Get("code")
+		if state == "" || code == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("state and code are required"))
+			return
+		}
+
+		id, err := provider.Exchange(r.Context(), state, code)
+		if err != nil {
+			log.Info("oidc exchange failed", sl.Err(err))
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.ErrorCode("invalid_login", "login failed"))
+			return
+		}
+
+		uid, err := users.GetOrCreateExternalUser(r.Context(), providerName, id.Subject, id.Email)
+		if err != nil {
+			log.Error("failed to map external user", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		tokens, err := sessions.Start(r.Context(), uid)
+		if err != nil {
+			log.Error("failed to start session", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("user logged in through oidc", slog.Int("uid", uid))
+
+		render.JSON(w, r, user.TokensResponse(uid, tokens))
+	}
+}
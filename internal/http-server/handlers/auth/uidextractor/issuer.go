@@ -0,0 +1,91 @@
+package uidextractor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"strconv"
+	"time"
+	"wish_list/internal/lib/keyset"
+)
+
+var ErrNoSigningKey = errors.New("signing key is not configured")
+
+type IssuerOptions struct {
+	// Kid names the HMAC key from the key set used for signing.
+	Kid      string
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+}
+
+// Issuer signs access tokens that Validator accepts.
+type Issuer struct {
+	keys *keyset.Set
+	opts IssuerOptions
+	now  func() time.Time
+}
+
+func NewIssuer(keys *keyset.Set, opts IssuerOptions) *Issuer {
+	return &Issuer{
+		keys: keys,
+		opts: opts,
+		now:  time.Now,
+	}
+}
+
+// Issue returns a signed access token for uid and its expiry time.
+func (i *Issuer) Issue(uid int) (string, time.Time, error) {
+	const op = "uidextractor.Issue"
+
+	secret, ok := i.keys.Secret(i.opts.Kid)
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("%s: %w: kid %q", op, ErrNoSigningKey, i.opts.Kid)
+	}
+
+	jti, err := newJTI()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := i.now()
+	exp := now.Add(i.opts.TTL)
+
+	claims := Claims{
+		RawUID: json.RawMessage(strconv.Itoa(uid)),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(uid),
+			Issuer:    i.opts.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+	if i.opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{i.opts.Audience}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if i.opts.Kid != "" {
+		token.Header["kid"] = i.opts.Kid
+	}
+
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return signed, exp, nil
+}
+
+func newJTI() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -1,32 +1,150 @@
 package uidextractor
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"strconv"
+	"time"
+	"wish_list/internal/lib/keyset"
 )
 
-var mySigningKey = "dhaw7dyaw8"
+// Rejection reasons returned by ValidateToken. Callers match them with
+// errors.Is to tell the client why the token was refused.
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrUnknownKey       = errors.New("token is signed with an unknown key")
+	ErrMissingExpiry    = errors.New("exp claim is missing")
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("invalid token issuer")
+	ErrInvalidAudience  = errors.New("invalid token audience")
+	ErrMissingUID       = errors.New("uid claim is missing")
+	ErrInvalidUID       = errors.New("uid claim is not numeric")
+	ErrTokenRevoked     = errors.New("token has been revoked")
+)
 
-func ValidateToken(tokenString string) (string, *jwt.Token, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(mySigningKey), nil
-	})
+// Denylist reports access tokens revoked before their expiry.
+type Denylist interface {
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Claims is the payload of the tokens accepted by the service. The uid claim
+// may be encoded either as a JSON number or as a numeric string.
+type Claims struct {
+	RawUID json.RawMessage `json:"uid,omitempty"`
+	UID    int             `json:"-"`
+	jwt.RegisteredClaims
+}
+
+type Options struct {
+	// Issuer and Audience are required to match when not empty.
+	Issuer   string
+	Audience string
+	// Leeway is the allowed clock skew for exp and nbf.
+	Leeway time.Duration
+	// AcceptMissingExpiry lets tokens without exp through. It is meant for
+	// the transition from tokens issued before exp became required and
+	// should be turned off once those are no longer in use.
+	AcceptMissingExpiry bool
+	// Denylist is consulted for tokens carrying a jti. Optional.
+	Denylist Denylist
+}
+
+type Validator struct {
+	keys   *keyset.Set
+	opts   Options
+	parser *jwt.Parser
+	now    func() time.Time
+}
+
+func New(keys *keyset.Set, opts Options) *Validator {
+	return &Validator{
+		keys: keys,
+		opts: opts,
+		// time based claims are checked by validate so leeway can be applied
+		parser: jwt.NewParser(jwt.WithoutClaimsValidation()),
+		now:    time.Now,
+	}
+}
+
+func (v *Validator) ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
+	var claims Claims
 
+	_, err := v.parser.ParseWithClaims(tokenString, &claims, v.keys.Keyfunc)
+	if errors.Is(err, jwt.ErrTokenMalformed) {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	if errors.Is(err, keyset.ErrUnknownKey) {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownKey, err)
+	}
 	if err != nil {
-		return "", token, err
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		uid, ok := claims["uid"].(float64)
-		if !ok {
-			return "", token, fmt.Errorf("uid claim is missing")
+	if err := v.validate(&claims); err != nil {
+		return nil, err
+	}
+
+	if v.opts.Denylist != nil && claims.ID != "" {
+		revoked, err := v.opts.Denylist.IsTokenRevoked(ctx, claims.ID)
+		if err != nil {
+			return nil, fmt.Errorf("check revocation: %w", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
 		}
-		return strconv.Itoa(int(uid)), token, nil
-	} else {
-		return "", token, fmt.Errorf("invalid token")
 	}
+
+	return &claims, nil
+}
+
+func (v *Validator) validate(c *Claims) error {
+	now := v.now()
+
+	if c.ExpiresAt == nil && !v.opts.AcceptMissingExpiry {
+		return ErrMissingExpiry
+	}
+	if !c.VerifyExpiresAt(now.Add(-v.opts.Leeway), false) {
+		return ErrTokenExpired
+	}
+	if !c.VerifyNotBefore(now.Add(v.opts.Leeway), false) {
+		return ErrTokenNotYetValid
+	}
+
+	if v.opts.Issuer != "" && !c.VerifyIssuer(v.opts.Issuer, true) {
+		return ErrInvalidIssuer
+	}
+	if v.opts.Audience != "" && !c.VerifyAudience(v.opts.Audience, true) {
+		return ErrInvalidAudience
+	}
+
+	uid, err := parseUID(c.RawUID)
+	if err != nil {
+		return err
+	}
+	c.UID = uid
+
+	return nil
+}
+
+func parseUID(raw json.RawMessage) (int, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, ErrMissingUID
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+
+	uid, err := strconv.Atoi(s)
+	if err != nil || uid <= 0 {
+		return 0, ErrInvalidUID
+	}
+
+	return uid, nil
 }
@@ -0,0 +1,201 @@
+package uidextractor_test
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"testing"
+	"time"
+	"wish_list/internal/config"
+	"wish_list/internal/http-server/handlers/auth/uidextractor"
+	"wish_list/internal/lib/keyset"
+)
+
+const (
+	secret = "test-secret"
+	leeway = 30 * time.Second
+)
+
+type denylist map[string]bool
+
+func (d denylist) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	if jti == "broken" {
+		return false, errors.New("storage is down")
+	}
+	return d[jti], nil
+}
+
+func newValidator(t *testing.T, opts uidextractor.Options) *uidextractor.Validator {
+	t.Helper()
+
+	keys, err := keyset.Load(config.Auth{Keys: []config.Key{
+		{Secret: secret},
+		{Kid: "v2", Secret: "second-secret"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return uidextractor.New(keys, opts)
+}
+
+type token struct {
+	kid    string
+	secret string
+	claims jwt.MapClaims
+}
+
+func (tk token) sign(t *testing.T) string {
+	t.Helper()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, tk.claims)
+	if tk.kid != "" {
+		tok.Header["kid"] = tk.kid
+	}
+
+	key := tk.secret
+	if key == "" {
+		key = secret
+	}
+
+	s, err := tok.SignedString([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// claims returns valid claims for uid 7 with the given changes applied; a
+// nil value removes the claim.
+func claims(changes jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+
+	c := jwt.MapClaims{
+		"uid": 7,
+		"iss": "wishlist",
+		"aud": "wishlist-api",
+		"exp": now.Add(time.Minute).Unix(),
+		"nbf": now.Add(-time.Minute).Unix(),
+		"jti": "live",
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+
+	return c
+}
+
+func TestValidateToken(t *testing.T) {
+	v := newValidator(t, uidextractor.Options{
+		Issuer:   "wishlist",
+		Audience: "wishlist-api",
+		Leeway:   leeway,
+		Denylist: denylist{"revoked": true},
+	})
+
+	now := time.Now()
+
+	cases := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"not a jwt", "abc.def", uidextractor.ErrMalformedToken},
+		{"wrong secret", token{secret: "other", claims: claims(nil)}.sign(t), uidextractor.ErrInvalidSignature},
+		{"unknown kid", token{kid: "v3", claims: claims(nil)}.sign(t), uidextractor.ErrUnknownKey},
+		{"missing exp", token{claims: claims(jwt.MapClaims{"exp": nil})}.sign(t), uidextractor.ErrMissingExpiry},
+		{"expired beyond leeway", token{claims: claims(jwt.MapClaims{"exp": now.Add(-leeway - 5*time.Second).Unix()})}.sign(t), uidextractor.ErrTokenExpired},
+		{"nbf beyond leeway", token{claims: claims(jwt.MapClaims{"nbf": now.Add(leeway + 5*time.Second).Unix()})}.sign(t), uidextractor.ErrTokenNotYetValid},
+		{"wrong issuer", token{claims: claims(jwt.MapClaims{"iss": "someone-else"})}.sign(t), uidextractor.ErrInvalidIssuer},
+		{"missing issuer", token{claims: claims(jwt.MapClaims{"iss": nil})}.sign(t), uidextractor.ErrInvalidIssuer},
+		{"wrong audience", token{claims: claims(jwt.MapClaims{"aud": []string{"other-api"}})}.sign(t), uidextractor.ErrInvalidAudience},
+		{"missing audience", token{claims: claims(jwt.MapClaims{"aud": nil})}.sign(t), uidextractor.ErrInvalidAudience},
+		{"missing uid", token{claims: claims(jwt.MapClaims{"uid": nil})}.sign(t), uidextractor.ErrMissingUID},
+		{"non-numeric uid", token{claims: claims(jwt.MapClaims{"uid": "alice"})}.sign(t), uidextractor.ErrInvalidUID},
+		{"negative uid", token{claims: claims(jwt.MapClaims{"uid": -1})}.sign(t), uidextractor.ErrInvalidUID},
+		{"denylisted jti", token{claims: claims(jwt.MapClaims{"jti": "revoked"})}.sign(t), uidextractor.ErrTokenRevoked},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := v.ValidateToken(context.Background(), tc.token); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	accepted := []struct {
+		name  string
+		token string
+	}{
+		{"valid", token{claims: claims(nil)}.sign(t)},
+		{"second key", token{kid: "v2", secret: "second-secret", claims: claims(nil)}.sign(t)},
+		{"uid as string", token{claims: claims(jwt.MapClaims{"uid": "7"})}.sign(t)},
+		{"expired within leeway", token{claims: claims(jwt.MapClaims{"exp": now.Add(-leeway + 5*time.Second).Unix()})}.sign(t)},
+		{"nbf within leeway", token{claims: claims(jwt.MapClaims{"nbf": now.Add(leeway - 5*time.Second).Unix()})}.sign(t)},
+		{"one of several audiences", token{claims: claims(jwt.MapClaims{"aud": []string{"other-api", "wishlist-api"}})}.sign(t)},
+		{"no jti", token{claims: claims(jwt.MapClaims{"jti": nil})}.sign(t)},
+	}
+
+	for _, tc := range accepted {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := v.ValidateToken(context.Background(), tc.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.UID != 7 {
+				t.Fatalf("uid = %d, want 7", c.UID)
+			}
+		})
+	}
+
+	_, err := v.ValidateToken(context.Background(), token{claims: claims(jwt.MapClaims{"jti": "broken"})}.sign(t))
+	if err == nil || errors.Is(err, uidextractor.ErrTokenRevoked) {
+		t.Fatalf("denylist failure: err = %v, want a non-rejection error", err)
+	}
+}
+
+func TestAcceptMissingExpiry(t *testing.T) {
+	v := newValidator(t, uidextractor.Options{AcceptMissingExpiry: true})
+
+	legacy := token{claims: jwt.MapClaims{"uid": "7"}}.sign(t)
+	if _, err := v.ValidateToken(context.Background(), legacy); err != nil {
+		t.Fatalf("token without exp: %v", err)
+	}
+
+	expired := token{claims: jwt.MapClaims{"uid": 7, "exp": time.Now().Add(-time.Minute).Unix()}}.sign(t)
+	if _, err := v.ValidateToken(context.Background(), expired); !errors.Is(err, uidextractor.ErrTokenExpired) {
+		t.Fatalf("expired token: %v, want %v", err, uidextractor.ErrTokenExpired)
+	}
+}
+
+func TestIssuedTokensValidate(t *testing.T) {
+	keys, err := keyset.Load(config.Auth{Keys: []config.Key{{Kid: "v2", Secret: secret}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := uidextractor.NewIssuer(keys, uidextractor.IssuerOptions{Kid: "v2", Issuer: "wishlist", Audience: "wishlist-api", TTL: time.Minute})
+	v := uidextractor.New(keys, uidextractor.Options{Issuer: "wishlist", Audience: "wishlist-api"})
+
+	signed, exp, err := issuer.Issue(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := v.ValidateToken(context.Background(), signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.UID != 42 || c.ID == "" || !c.ExpiresAt.Time.Equal(exp.Truncate(time.Second)) {
+		t.Fatalf("claims = %+v, expiry %v", c, exp)
+	}
+
+	if _, _, err := uidextractor.NewIssuer(keys, uidextractor.IssuerOptions{Kid: "missing"}).Issue(42); !errors.Is(err, uidextractor.ErrNoSigningKey) {
+		t.Fatalf("issue with an unknown kid: %v, want %v", err, uidextractor.ErrNoSigningKey)
+	}
+}
@@ -0,0 +1,303 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/middleware/auth"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/session"
+	"wish_list/internal/storage"
+)
+
+const (
+	minLoginLen    = 3
+	maxLoginLen    = 64
+	minPasswordLen = 8
+	// bcrypt ignores everything after the first 72 bytes
+	maxPasswordLen = 72
+)
+
+// dummyHash is compared against when the login is unknown so that both
+// failure paths take the same time.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+type Request struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+type Response struct {
+	resp.Response
+	UID              int    `json:"uid,omitempty"`
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type User interface {
+	CreateUser(ctx context.Context, login, passwordHash string) (int, error)
+	GetUserByLogin(ctx context.Context, login string) (entity.User, error)
+}
+
+type Sessions interface {
+	Start(ctx context.Context, uid int) (session.Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (session.Tokens, error)
+	Logout(ctx context.Context, uid int, jti string, accessExp time.Time, refreshToken string) error
+}
+
+func Register(log *slog.Logger, user User, sessions Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.user.Register"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		req, ok := decode(w, r, log)
+		if !ok {
+			return
+		}
+
+		if msg := validate(req); msg != "" {
+			log.Info("invalid credentials format", slog.String("reason", msg))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(msg))
+			return
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Error("failed to hash password", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		uid, err := user.CreateUser(r.Context(), req.Login, string(hash))
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Info("user already exists", slog.String("login", req.Login))
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("user already exists"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to create user", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("user registered", slog.Int("uid", uid))
+
+		startSession(w, r, log, sessions, uid)
+	}
+}
+
+func Login(log *slog.Logger, user User, sessions Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.user.Login"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		req, ok := decode(w, r, log)
+		if !ok {
+			return
+		}
+
+		u, err := user.GetUserByLogin(r.Context(), req.Login)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+
+			log.Info("unknown login")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("invalid login or password"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get user", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.Password)); err != nil {
+			log.Info("wrong password", slog.Int("uid", u.UID))
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("invalid login or password"))
+			return
+		}
+
+		log.Info("user logged in", slog.Int("uid", u.UID))
+
+		startSession(w, r, log, sessions, u.UID)
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, log *slog.Logger) (Request, bool) {
+	var req Request
+
+	err := render.DecodeJSON(r.Body, &req)
+	if errors.Is(err, io.EOF) {
+		log.Error("request body is empty")
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("empty request"))
+		return req, false
+	}
+	if err != nil {
+		log.Error("failed to decode request body", sl.Err(err))
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("failed to decode request"))
+		return req, false
+	}
+
+	req.Login = strings.ToLower(strings.TrimSpace(req.Login))
+
+	return req, true
+}
+
+func validate(req Request) string {
+	if n := utf8.RuneCountInString(req.Login); n < minLoginLen || n > maxLoginLen {
+		return "login must be between 3 and 64 characters"
+	}
+	if n := len(req.Password); n < minPasswordLen || n > maxPasswordLen {
+		return "password must be between 8 and 72 bytes"
+	}
+	return ""
+}
+
+func Refresh(log *slog.Logger, sessions Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.user.Refresh"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req RefreshRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil || req.RefreshToken == "" {
+			log.Info("refresh token is missing")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("refresh_token is required"))
+			return
+		}
+
+		tokens, err := sessions.Refresh(r.Context(), req.RefreshToken)
+		if errors.Is(err, storage.ErrTokenReused) {
+			log.Warn("refresh token reuse detected, family revoked")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.ErrorCode("token_reused", "refresh token has already been used"))
+			return
+		}
+		if errors.Is(err, storage.ErrTokenExpired) {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.ErrorCode("token_expired", "refresh token is expired"))
+			return
+		}
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.ErrorCode("invalid_token", "invalid refresh token"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to refresh session", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("session refreshed")
+
+		render.JSON(w, r, TokensResponse(0, tokens))
+	}
+}
+
+// Logout revokes the access token used for the request and, when given,
+// the refresh token family.
+func Logout(log *slog.Logger, sessions Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.user.Logout"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		id, ok := auth.FromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
+			return
+		}
+
+		var req RefreshRequest
+
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		if err := sessions.Logout(r.Context(), id.UID, id.TokenID, id.ExpiresAt, req.RefreshToken); err != nil {
+			log.Error("failed to logout", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("user logged out", slog.Int("uid", id.UID))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+func startSession(w http.ResponseWriter, r *http.Request, log *slog.Logger, sessions Sessions, uid int) {
+	tokens, err := sessions.Start(r.Context(), uid)
+	if err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, resp.Error("internal error"))
+		return
+	}
+
+	render.JSON(w, r, TokensResponse(uid, tokens))
+}
+
+// TokensResponse renders a token pair as returned by every login flow.
+func TokensResponse(uid int, tokens session.Tokens) Response {
+	return Response{
+		Response:         resp.OK(),
+		UID:              uid,
+		AccessToken:      tokens.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(time.Until(tokens.AccessExpiresAt).Seconds()),
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresIn: int(time.Until(tokens.RefreshExpiresAt).Seconds()),
+	}
+}
@@ -0,0 +1,76 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/storage"
+)
+
+type Clicks interface {
+	CountClick(ctx context.Context, itemId int) (string, error)
+}
+
+// Links rewrites an outbound item link into its affiliate form.
+type Links interface {
+	Rewrite(link string) string
+}
+
+// Redirect counts a click on an item's link and sends the visitor to it,
+// rewritten into its affiliate form when links is set.
+func Redirect(log *slog.Logger, clicks Clicks, links Links) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.outbound.Redirect"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		itemId, err := strconv.Atoi(chi.URLParam(r, "gift_id"))
+		if err != nil {
+			log.Info("invalid item id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid item id"))
+			return
+		}
+
+		link, err := clicks.CountClick(r.Context(), itemId)
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Info("item not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to count click", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		// Links saved before URLs were validated may be anything; only web
+		// pages are redirected to.
+		if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Info("item has no web link")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item has no link"))
+			return
+		}
+
+		if links != nil {
+			link = links.Rewrite(link)
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, link, http.StatusFound)
+	}
+}
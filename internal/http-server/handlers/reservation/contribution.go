@@ -0,0 +1,200 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"wish_list/internal/entity"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/storage"
+)
+
+// PledgeRequest is a contribution towards a group gift, in minor units of
+// the item's currency.
+type PledgeRequest struct {
+	Amount int64  `json:"amount"`
+	Name   string `json:"name"`
+}
+
+type PledgeResponse struct {
+	resp.Response
+	ContributionId int `json:"contribution_id"`
+}
+
+type Contributions interface {
+	Pledge(ctx context.Context, alias string, itemId int, r entity.Reserver, amount int64) (int, error)
+	Withdraw(ctx context.Context, alias string, itemId, contributionId int, r entity.Reserver) error
+}
+
+func Pledge(log *slog.Logger, contributions Contributions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.reservation.Pledge"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		contributor, ok := fromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		alias := chi.URLParam(r, "alias")
+
+		itemId, err := strconv.Atoi(chi.URLParam(r, "itemId"))
+		if err != nil {
+			log.Info("invalid item id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid item id"))
+			return
+		}
+
+		var req PledgeRequest
+
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		if req.Amount <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("amount must be positive"))
+			return
+		}
+
+		contributor.Name = strings.TrimSpace(req.Name)
+		if utf8.RuneCountInString(contributor.Name) > maxNameLen {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("name must be at most 100 characters"))
+			return
+		}
+
+		id, err := contributions.Pledge(r.Context(), alias, itemId, contributor, req.Amount)
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Info("item not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("owner tried to pledge for own item")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("you cannot contribute to your own wishlist"))
+			return
+		}
+		if errors.Is(err, storage.ErrNoPrice) {
+			log.Info("item has no price")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("item has no price to collect"))
+			return
+		}
+		if errors.Is(err, storage.ErrItemReserved) {
+			log.Info("item already reserved")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("item is already reserved"))
+			return
+		}
+		if errors.Is(err, storage.ErrOverfunded) {
+			log.Info("pledge exceeds remaining amount")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("amount exceeds what is left to collect"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to pledge", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("contribution pledged", slog.Int("contribution_id", id))
+
+		render.JSON(w, r, PledgeResponse{
+			Response:       resp.OK(),
+			ContributionId: id,
+		})
+	}
+}
+
+func Withdraw(log *slog.Logger, contributions Contributions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.reservation.Withdraw"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		contributor, ok := fromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		alias := chi.URLParam(r, "alias")
+
+		itemId, err := strconv.Atoi(chi.URLParam(r, "itemId"))
+		if err != nil {
+			log.Info("invalid item id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid item id"))
+			return
+		}
+
+		contributionId, err := strconv.Atoi(chi.URLParam(r, "contributionId"))
+		if err != nil {
+			log.Info("invalid contribution id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid contribution id"))
+			return
+		}
+
+		err = contributions.Withdraw(r.Context(), alias, itemId, contributionId, contributor)
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Info("item not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrContributionNotFound) {
+			log.Info("contribution not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("contribution not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("contribution belongs to someone else")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("contribution belongs to someone else"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to withdraw", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("contribution withdrawn")
+
+		render.JSON(w, r, resp.OK())
+	}
+}
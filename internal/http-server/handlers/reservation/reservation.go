@@ -0,0 +1,199 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/middleware/auth"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/storage"
+)
+
+const (
+	maxNameLen  = 100
+	maxQuantity = 999
+)
+
+// Request claims Quantity units, one by default. Name optionally names the
+// reserver for the wishlist owner, e.g. "Aunt Mary"; the owner sees it
+// only when surprise mode is off.
+type Request struct {
+	Quantity int    `json:"quantity"`
+	Name     string `json:"name"`
+}
+
+type Reservations interface {
+	ReserveItem(ctx context.Context, alias string, itemId int, r entity.Reserver, quantity int) error
+	UnreserveItem(ctx context.Context, alias string, itemId int, r entity.Reserver) error
+}
+
+func Reserve(log *slog.Logger, reservations Reservations) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.reservation.Reserve"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		reserver, ok := fromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		alias := chi.URLParam(r, "alias")
+
+		itemId, err := strconv.Atoi(chi.URLParam(r, "itemId"))
+		if err != nil {
+			log.Info("invalid item id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid item id"))
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		reserver.Name = strings.TrimSpace(req.Name)
+		if utf8.RuneCountInString(reserver.Name) > maxNameLen {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("name must be at most 100 characters"))
+			return
+		}
+
+		if req.Quantity == 0 {
+			req.Quantity = 1
+		}
+		if req.Quantity < 0 || req.Quantity > maxQuantity {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("quantity must be between 1 and 999"))
+			return
+		}
+
+		err = reservations.ReserveItem(r.Context(), alias, itemId, reserver, req.Quantity)
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Info("item not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("owner tried to reserve own item")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("you cannot reserve items of your own wishlist"))
+			return
+		}
+		if errors.Is(err, storage.ErrNotEnoughLeft) {
+			log.Info("not enough units left")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("not enough units left to reserve"))
+			return
+		}
+		if errors.Is(err, storage.ErrItemFunded) {
+			log.Info("item is a group gift")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("item is a group gift, contribute instead"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to reserve item", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("item reserved")
+
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+func Unreserve(log *slog.Logger, reservations Reservations) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.reservation.Unreserve"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		reserver, ok := fromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		alias := chi.URLParam(r, "alias")
+
+		itemId, err := strconv.Atoi(chi.URLParam(r, "itemId"))
+		if err != nil {
+			log.Info("invalid item id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid item id"))
+			return
+		}
+
+		err = reservations.UnreserveItem(r.Context(), alias, itemId, reserver)
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Info("item not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrNotReserved) {
+			log.Info("item not reserved by caller")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("you have not reserved this item"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to cancel reservation", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("reservation cancelled")
+
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+// fromRequest identifies the caller by account or, failing that, by guest
+// token, and writes the error response when neither is present.
+func fromRequest(w http.ResponseWriter, r *http.Request) (entity.Reserver, bool) {
+	if id, ok := auth.FromContext(r.Context()); ok {
+		if !id.HasScope(auth.ScopeShareWrite) {
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.ErrorCode(auth.CodeInsufficientScope, "token lacks scope "+auth.ScopeShareWrite))
+			return entity.Reserver{}, false
+		}
+
+		return entity.Reserver{UID: id.UID}, true
+	}
+
+	if guestId, ok := auth.GuestFromContext(r.Context()); ok {
+		return entity.Reserver{GuestId: guestId}, true
+	}
+
+	w.WriteHeader(http.StatusUnauthorized)
+	render.JSON(w, r, resp.ErrorCode(auth.CodeMissingToken, "sign in or send a guest token"))
+
+	return entity.Reserver{}, false
+}
@@ -1,20 +1,45 @@
 package sharelist
 
 import (
+	"context"
+	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"log/slog"
 	"net/http"
 	"wish_list/internal/entity"
+	"wish_list/internal/http-server/middleware/auth"
 	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/money"
+	"wish_list/internal/storage"
 )
 
 type ShareList interface {
-	GetList(alias string) ([]entity.GiftList, error)
+	GetList(ctx context.Context, alias string) (entity.WishList, []entity.GiftList, error)
 }
 
-func GetList(log *slog.Logger, shareList ShareList) http.HandlerFunc {
+// Links rewrites outbound item links into their affiliate form.
+type Links interface {
+	Apply(list []entity.GiftList)
+}
+
+// Response is what guests see: the public part of the wishlist and its items.
+type Response struct {
+	Name        string            `json:"name"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	EventDate   string            `json:"event_date,omitempty"`
+	CoverEmoji  string            `json:"cover_emoji"`
+	CoverColor  string            `json:"cover_color"`
+	Items       []entity.GiftList `json:"items"`
+	Totals      map[string]int64  `json:"totals"`
+}
+
+// GetList returns the wishlist published under the alias. When links is set,
+// item links are shown in their affiliate form.
+func GetList(log *slog.Logger, shareList ShareList, links Links) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.sharelist.GetList"
 
@@ -23,6 +48,13 @@ func GetList(log *slog.Logger, shareList ShareList) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
+		if id, ok := auth.FromContext(r.Context()); ok && !id.HasScope(auth.ScopeShareRead) {
+			log.Info("api token lacks scope", slog.String("scope", auth.ScopeShareRead))
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.ErrorCode(auth.CodeInsufficientScope, "token lacks scope "+auth.ScopeShareRead))
+			return
+		}
+
 		alias := chi.URLParam(r, "alias")
 		if alias == "" {
 			log.Info("alias is empty")
@@ -31,20 +63,95 @@ func GetList(log *slog.Logger, shareList ShareList) http.HandlerFunc {
 			return
 		}
 
-		list, err := shareList.GetList(alias)
+		wl, list, err := shareList.GetList(r.Context(), alias)
+		if errors.Is(err, storage.ErrListNotFound) {
+			log.Info("wishlist not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("wishlist not found"))
+			return
+		}
 		if err != nil {
-			log.Error("failed to get list: ", err)
+			log.Error("failed to get list", sl.Err(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			render.JSON(w, r, resp.Error("unable to retrieve the wishlist"))
 			return
 		}
 
 		if list == nil {
-			w.WriteHeader(http.StatusNotFound)
-			render.JSON(w, r, resp.Error("wishlist not found"))
-			return
+			list = []entity.GiftList{}
+		}
+
+		if links != nil {
+			links.Apply(list)
 		}
 
-		render.JSON(w, r, list)
+		forViewer(r.Context(), wl, list)
+
+		render.JSON(w, r, Response{
+			Name:        wl.Name,
+			Alias:       wl.Alias,
+			Description: wl.Description,
+			EventDate:   wl.EventDate,
+			CoverEmoji:  wl.CoverEmoji,
+			CoverColor:  wl.CoverColor,
+			Items:       list,
+			Totals:      money.Totals(list),
+		})
+	}
+}
+
+// forViewer adapts reservations and group gift pledges to the viewer. The
+// owner sees who took what unless the list is in surprise mode, in which
+// case nothing is shown. Guests see how many units are left and only their
+// own reservations and pledges. Price alerts, link health, click counts and
+// the stored form of affiliate links are private to the owner's dashboard
+// and never shown here.
+func forViewer(ctx context.Context, wl entity.WishList, list []entity.GiftList) {
+	var viewer entity.Reserver
+
+	if id, ok := auth.FromContext(ctx); ok {
+		viewer.UID = id.UID
+	} else if guestId, ok := auth.GuestFromContext(ctx); ok {
+		viewer.GuestId = guestId
+	}
+
+	for i := range list {
+		list[i].PriceAlert = nil
+		list[i].Link = nil
+		list[i].SourceUrl = ""
+		list[i].Clicks = 0
+	}
+
+	if viewer.UID != 0 && viewer.UID == wl.UID {
+		if wl.Surprise {
+			for i := range list {
+				list[i].Remaining = nil
+				list[i].Reservations = nil
+				list[i].Funding = nil
+			}
+		}
+		return
+	}
+
+	for i := range list {
+		var mine []entity.Reservation
+		for _, res := range list[i].Reservations {
+			if res.HeldBy(viewer) {
+				res.Mine = true
+				mine = append(mine, res)
+			}
+		}
+		list[i].Reservations = mine
+
+		if f := list[i].Funding; f != nil {
+			var mine []entity.Contribution
+			for _, c := range f.Contributions {
+				if c.MadeBy(viewer) {
+					c.Mine = true
+					mine = append(mine, c)
+				}
+			}
+			f.Contributions = mine
+		}
 	}
 }
@@ -0,0 +1,84 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/middleware/auth"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/storage"
+)
+
+// HistoryResponse lists the prices observed on the item's page, oldest
+// first.
+type HistoryResponse struct {
+	GiftId  int                 `json:"gift_id"`
+	History []entity.PricePoint `json:"history"`
+}
+
+type PriceHistory interface {
+	PriceHistory(ctx context.Context, itemId, uid int) ([]entity.PricePoint, error)
+}
+
+func GetPriceHistory(log *slog.Logger, history PriceHistory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.item.GetPriceHistory"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
+			return
+		}
+
+		itemId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Info("invalid item id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid item id"))
+			return
+		}
+
+		points, err := history.PriceHistory(r.Context(), itemId, uid)
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Info("item not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("access denied")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get price history", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		if points == nil {
+			points = []entity.PricePoint{}
+		}
+
+		render.JSON(w, r, HistoryResponse{
+			GiftId:  itemId,
+			History: points,
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package item
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -8,30 +10,102 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 	"wish_list/internal/entity"
-	"wish_list/internal/http-server/handlers/auth/uidextractor"
+	"wish_list/internal/http-server/middleware/auth"
 	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/money"
+	"wish_list/internal/lib/urlnorm"
+	"wish_list/internal/storage"
 )
 
 type Request struct {
-	WishListId int    `json:"wish_list_id"`
-	GiftName   string `json:"gift_name"`
-	Url        string `json:"url"`
+	WishListId  int            `json:"wish_list_id"`
+	GiftName    string         `json:"gift_name"`
+	Url         string         `json:"url"`
+	Description string         `json:"description"`
+	ImageUrl    string         `json:"image_url"`
+	Options     entity.Options `json:"options"`
+	Price       *entity.Price  `json:"price"`
+	Quantity    int            `json:"quantity"`
+	Priority    string         `json:"priority"`
 }
 
 type Response struct {
 	GiftId int `json:"gift_id"`
 }
 
+// ListResponse is the owner's view of a wishlist: its items and the sum of
+// their prices per currency.
+type ListResponse struct {
+	Items  []entity.GiftList `json:"items"`
+	Totals map[string]int64  `json:"totals"`
+}
+
+// UpdateRequest holds the fields of a partial item update; omitted fields
+// keep their current values. A null price or price_alert removes it. The
+// price of an item with pledges cannot change.
+type UpdateRequest struct {
+	GiftName    *string         `json:"gift_name"`
+	Url         *string         `json:"url"`
+	Description *string         `json:"description"`
+	ImageUrl    *string         `json:"image_url"`
+	Options     *entity.Options `json:"options"`
+	Price       json.RawMessage `json:"price"`
+	PriceAlert  json.RawMessage `json:"price_alert"`
+	Quantity    *int            `json:"quantity"`
+	Priority    *string         `json:"priority"`
+}
+
+// ReorderRequest lists every item of a wishlist in the desired order.
+type ReorderRequest struct {
+	GiftIds []int `json:"gift_ids"`
+}
+
+const (
+	maxNameLen        = 200
+	maxUrlLen         = 2048
+	maxQuantity       = 999
+	maxDescriptionLen = 2000
+	maxOptions        = 20
+	maxOptionKeyLen   = 50
+	maxOptionValueLen = 200
+)
+
+const (
+	invalidUrl   = "url must be an http or https URL of at most 2048 characters"
+	duplicateUrl = "the wishlist already has an item with this url"
+)
+
 type Item interface {
-	CreateItem(wishlistId int, giftName, url string) (int, error)
-	GetByWishId(wishListId int) ([]entity.GiftList, error)
-	DelItemById(itemId int) error
+	CreateItem(ctx context.Context, wishlistId, uid int, gift entity.GiftCreate) (int, error)
+	GetByWishId(ctx context.Context, wishListId, uid int) ([]entity.GiftList, error)
+	DelItemById(ctx context.Context, itemId, uid int) error
+	UpdateItem(ctx context.Context, itemId, uid int, upd entity.GiftUpdate) (entity.GiftList, error)
+	ReorderItems(ctx context.Context, wishListId, uid int, giftIds []int) error
+}
+
+// Links rewrites outbound item links into their affiliate form when items
+// are shown; the stored links stay as they are.
+type Links interface {
+	Apply(list []entity.GiftList)
+}
+
+// Enricher fills in the details of new items from their web pages in the
+// background.
+type Enricher interface {
+	Enqueue(itemId int, url string) bool
 }
 
-func Create(log *slog.Logger, item Item) http.HandlerFunc {
+// Create adds an item to a wishlist. The URL is required and stored in its
+// canonical form, so a second item with the same link in one wishlist is
+// rejected. When enricher is set, a missing name, image or price is looked
+// up on the item's page after the response is sent.
+func Create(log *slog.Logger, item Item, enricher Enricher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.item.Create"
 
@@ -40,51 +114,113 @@ func Create(log *slog.Logger, item Item) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
-		splitToken := strings.Split(authHeader, "Bearer ")
-		if len(splitToken) != 2 {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("empty request"))
 			return
 		}
-
-		tokenString := splitToken[1]
-
-		uid, token, err := uidextractor.ValidateToken(tokenString)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
 			return
 		}
 
-		_, _ = token, uid
-
-		var req Request
+		log.Info("request body decoded", slog.Any("request", req))
 
-		err = render.DecodeJSON(r.Body, &req)
-		if errors.Is(err, io.EOF) {
-			log.Error("request body is empty")
+		// The name may be left empty for the enricher to fill in.
+		req.GiftName = strings.TrimSpace(req.GiftName)
+		if utf8.RuneCountInString(req.GiftName) > maxNameLen {
+			log.Info("invalid item name")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("gift_name must be at most 200 characters"))
+			return
+		}
 
-			render.JSON(w, r, resp.Error("empty request"))
+		details, msg := validateDetails(req.Description, req.ImageUrl, req.Options)
+		if msg != "" {
+			log.Info("invalid item details", slog.String("reason", msg))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(msg))
+			return
+		}
 
+		link, ok := urlnorm.Normalize(req.Url)
+		if !ok {
+			log.Info("invalid url")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(invalidUrl))
 			return
 		}
-		if err != nil {
-			log.Error("failed to decode request body: ", err)
 
-			render.JSON(w, r, resp.Error("failed to decode request"))
+		price, msg := validatePrice(req.Price, "price")
+		if msg != "" {
+			log.Info("invalid price", slog.String("reason", msg))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(msg))
+			return
+		}
 
+		if req.Quantity == 0 {
+			req.Quantity = 1
+		}
+		if req.Quantity < 0 || req.Quantity > maxQuantity {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("quantity must be between 1 and 999"))
 			return
 		}
 
-		log.Info("request body decoded", slog.Any("request", req))
+		if req.Priority == "" {
+			req.Priority = entity.PriorityNiceToHave
+		}
+		if !validPriority(req.Priority) {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("priority must be must_have or nice_to_have"))
+			return
+		}
 
-		giftId, err := item.CreateItem(req.WishListId, req.GiftName, req.Url)
+		giftId, err := item.CreateItem(r.Context(), req.WishListId, uid, entity.GiftCreate{
+			Name:        req.GiftName,
+			Url:         link,
+			Description: details.Description,
+			ImageUrl:    details.ImageUrl,
+			Options:     details.Options,
+			Price:       price,
+			Quantity:    req.Quantity,
+			Priority:    req.Priority,
+		})
+		if errors.Is(err, storage.ErrListNotFound) {
+			log.Info("wishlist not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("wishlist not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("access denied")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if errors.Is(err, storage.ErrItemExists) {
+			log.Info("duplicate item url")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error(duplicateUrl))
+			return
+		}
 		if err != nil {
-			log.Error("failed to add item: ", err)
+			log.Error("failed to add item", sl.Err(err))
 
 			render.JSON(w, r, resp.Error("internal error"))
 
@@ -93,6 +229,12 @@ func Create(log *slog.Logger, item Item) http.HandlerFunc {
 
 		log.Info("item added")
 
+		if enricher != nil && (req.GiftName == "" || details.ImageUrl == "" || price == nil) {
+			if !enricher.Enqueue(giftId, link) {
+				log.Warn("item enrichment skipped")
+			}
+		}
+
 		render.JSON(w, r, Response{
 			GiftId: giftId,
 		})
@@ -100,7 +242,9 @@ func Create(log *slog.Logger, item Item) http.HandlerFunc {
 	}
 }
 
-func GetByWishId(log *slog.Logger, item Item) http.HandlerFunc {
+// GetByWishId returns the owner's view of a wishlist's items. When links
+// is set, item links are shown in their affiliate form.
+func GetByWishId(log *slog.Logger, item Item, links Links) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.item.GetByWishId"
 
@@ -109,28 +253,14 @@ func GetByWishId(log *slog.Logger, item Item) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
-			return
-		}
-
-		splitToken := strings.Split(authHeader, "Bearer ")
-		if len(splitToken) != 2 {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := splitToken[1]
-
-		uid, token, err := uidextractor.ValidateToken(tokenString)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
-		_, _ = token, uid
-
 		wishListId := chi.URLParam(r, "wishlistId")
 		if wishListId == "" {
 			log.Info("wishListId is empty")
@@ -141,14 +271,26 @@ func GetByWishId(log *slog.Logger, item Item) http.HandlerFunc {
 
 		wishListIdInt, err := strconv.Atoi(wishListId)
 		if err != nil {
-			log.Error("Invalid wishListId format: ", err)
+			log.Error("Invalid wishListId format", sl.Err(err))
 			http.Error(w, "Invalid wishListId", http.StatusBadRequest)
 			return
 		}
 
-		lists, err := item.GetByWishId(wishListIdInt)
+		lists, err := item.GetByWishId(r.Context(), wishListIdInt, uid)
+		if errors.Is(err, storage.ErrListNotFound) {
+			log.Info("wishlist not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("wishlist not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("access denied")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
 		if err != nil {
-			log.Error("failed to get items: ", err)
+			log.Error("failed to get items", sl.Err(err))
 
 			render.JSON(w, r, resp.Error("internal error"))
 
@@ -157,7 +299,18 @@ func GetByWishId(log *slog.Logger, item Item) http.HandlerFunc {
 
 		log.Info("items got")
 
-		render.JSON(w, r, lists)
+		if lists == nil {
+			lists = []entity.GiftList{}
+		}
+
+		if links != nil {
+			links.Apply(lists)
+		}
+
+		render.JSON(w, r, ListResponse{
+			Items:  lists,
+			Totals: money.Totals(lists),
+		})
 
 	}
 }
@@ -175,60 +328,433 @@ func Delete(log *slog.Logger, item Item) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
-		splitToken := strings.Split(authHeader, "Bearer ")
-		if len(splitToken) != 2 {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
+		var req RequestForDel
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
 			return
 		}
 
-		tokenString := splitToken[1]
+		log.Info("request body decoded", slog.Any("request", req))
 
-		uid, token, err := uidextractor.ValidateToken(tokenString)
+		err = item.DelItemById(r.Context(), req.ItemId, uid)
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Info("item not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("access denied")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			log.Error("failed to delete item", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("item deleted")
+
+		render.JSON(w, r, http.StatusOK)
+
+	}
+}
+
+// Update changes the supplied fields of an item and returns the item as the
+// owner sees it, with its link rewritten like in GetByWishId.
+func Update(log *slog.Logger, item Item, links Links) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.item.Update"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
-		_, _ = token, uid
+		itemId, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			log.Info("invalid item id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid item id"))
+			return
+		}
 
-		var req RequestForDel
+		var req UpdateRequest
 
 		err = render.DecodeJSON(r.Body, &req)
 		if errors.Is(err, io.EOF) {
 			log.Error("request body is empty")
-
+			w.WriteHeader(http.StatusBadRequest)
 			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
 
+		upd, msg := req.toUpdate()
+		if msg != "" {
+			log.Info("invalid update", slog.String("reason", msg))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(msg))
+			return
+		}
+
+		updated, err := item.UpdateItem(r.Context(), itemId, uid, upd)
+		if errors.Is(err, storage.ErrItemNotFound) {
+			log.Info("item not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("item not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("access denied")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if errors.Is(err, storage.ErrItemExists) {
+			log.Info("duplicate item url")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error(duplicateUrl))
+			return
+		}
+		if errors.Is(err, storage.ErrQuantityBelow) {
+			log.Info("quantity below reserved units")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("quantity is below the units already reserved"))
+			return
+		}
+		if errors.Is(err, storage.ErrItemPledged) {
+			log.Info("price change of a pledged item")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("price cannot change while the item has pledges"))
+			return
+		}
+		if errors.Is(err, storage.ErrHiddenClaims) {
+			log.Info("update conflicts with hidden claims")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("this change is not possible while the wishlist is in surprise mode"))
 			return
 		}
 		if err != nil {
-			log.Error("failed to decode request body: ", err)
+			log.Error("failed to update item", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
 
-			render.JSON(w, r, resp.Error("failed to decode request"))
+		log.Info("item updated")
 
+		if links != nil {
+			list := []entity.GiftList{updated}
+			links.Apply(list)
+			updated = list[0]
+		}
+
+		render.JSON(w, r, updated)
+	}
+}
+
+// Reorder applies a new manual order to all items of a wishlist at once.
+func Reorder(log *slog.Logger, item Item) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.item.Reorder"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
-		log.Info("request body decoded", slog.Any("request", req))
+		wishListId, err := strconv.Atoi(chi.URLParam(r, "wishlistId"))
+		if err != nil {
+			log.Info("invalid wishlist id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid wishlist id"))
+			return
+		}
+
+		var req ReorderRequest
 
-		err = item.DelItemById(req.ItemId)
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
 		if err != nil {
-			log.Error("failed to delete item: ", err)
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
 
-			render.JSON(w, r, resp.Error("internal error"))
+		log.Info("request body decoded", slog.Any("request", req))
 
+		err = item.ReorderItems(r.Context(), wishListId, uid, req.GiftIds)
+		if errors.Is(err, storage.ErrListNotFound) {
+			log.Info("wishlist not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("wishlist not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("access denied")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if errors.Is(err, storage.ErrOrderMismatch) {
+			log.Info("order does not match the wishlist items")
+			w.WriteHeader(http.StatusConflict)
+			render.JSON(w, r, resp.Error("gift_ids must list every item of the wishlist exactly once"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to reorder items", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
 			return
 		}
 
-		log.Info("item deleted")
+		log.Info("items reordered")
 
-		render.JSON(w, r, http.StatusOK)
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+// toUpdate validates the supplied fields and returns a message for the
+// client when they are not acceptable.
+func (req UpdateRequest) toUpdate() (entity.GiftUpdate, string) {
+	var upd entity.GiftUpdate
+
+	if req.GiftName != nil {
+		name := strings.TrimSpace(*req.GiftName)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLen {
+			return upd, "gift_name must be between 1 and 200 characters"
+		}
+		upd.Name = &name
+	}
 
+	if req.Url != nil {
+		link, ok := urlnorm.Normalize(*req.Url)
+		if !ok {
+			return upd, invalidUrl
+		}
+		upd.Url = &link
 	}
+
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if utf8.RuneCountInString(description) > maxDescriptionLen {
+			return upd, "description must be at most 2000 characters"
+		}
+		upd.Description = &description
+	}
+
+	if req.ImageUrl != nil {
+		imageUrl, msg := validateImageUrl(*req.ImageUrl)
+		if msg != "" {
+			return upd, msg
+		}
+		upd.ImageUrl = &imageUrl
+	}
+
+	if req.Options != nil {
+		options, msg := validateOptions(*req.Options)
+		if msg != "" {
+			return upd, msg
+		}
+		upd.Options = &options
+	}
+
+	var msg string
+
+	if upd.Price, upd.ClearPrice, msg = decodePrice(req.Price, "price"); msg != "" {
+		return upd, msg
+	}
+	if upd.PriceAlert, upd.ClearAlert, msg = decodePrice(req.PriceAlert, "price_alert"); msg != "" {
+		return upd, msg
+	}
+
+	if req.Quantity != nil {
+		if *req.Quantity < 1 || *req.Quantity > maxQuantity {
+			return upd, "quantity must be between 1 and 999"
+		}
+		upd.Quantity = req.Quantity
+	}
+
+	if req.Priority != nil {
+		if !validPriority(*req.Priority) {
+			return upd, "priority must be must_have or nice_to_have"
+		}
+		upd.Priority = req.Priority
+	}
+
+	if upd == (entity.GiftUpdate{}) {
+		return upd, "nothing to update"
+	}
+
+	return upd, ""
+}
+
+// details are the optional descriptive fields of an item.
+type details struct {
+	Description string
+	ImageUrl    string
+	Options     entity.Options
+}
+
+// validateDetails trims and checks the optional descriptive fields of a new
+// item, returning a message for the client when they are not acceptable.
+func validateDetails(description, imageUrl string, options entity.Options) (details, string) {
+	var d details
+
+	d.Description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(d.Description) > maxDescriptionLen {
+		return d, "description must be at most 2000 characters"
+	}
+
+	var msg string
+	if d.ImageUrl, msg = validateImageUrl(imageUrl); msg != "" {
+		return d, msg
+	}
+	if d.Options, msg = validateOptions(options); msg != "" {
+		return d, msg
+	}
+
+	return d, ""
+}
+
+// validateImageUrl accepts an empty value or an absolute http(s) URL.
+func validateImageUrl(raw string) (string, string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ""
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || len(raw) > maxUrlLen || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "image_url must be an http or https URL of at most 2048 characters"
+	}
+
+	return raw, ""
+}
+
+// validateOptions trims variant attributes and checks their number and
+// length. Keys must not be empty.
+func validateOptions(o entity.Options) (entity.Options, string) {
+	if len(o) > maxOptions {
+		return nil, "at most 20 options are allowed"
+	}
+
+	options := make(entity.Options, len(o))
+	for k, v := range o {
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k == "" || utf8.RuneCountInString(k) > maxOptionKeyLen {
+			return nil, "option names must be between 1 and 50 characters"
+		}
+		if utf8.RuneCountInString(v) > maxOptionValueLen {
+			return nil, "option values must be at most 200 characters"
+		}
+		options[k] = v
+	}
+
+	return options, ""
+}
+
+func validPriority(p string) bool {
+	return p == entity.PriorityMustHave || p == entity.PriorityNiceToHave
+}
+
+// decodePrice reads an optional price field of a partial update: absent
+// leaves it as is, null clears it, an object sets it.
+func decodePrice(raw json.RawMessage, field string) (*entity.Price, bool, string) {
+	if len(raw) == 0 {
+		return nil, false, ""
+	}
+	if string(raw) == "null" {
+		return nil, true, ""
+	}
+
+	var p entity.Price
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, false, field + " must be an object with amount and currency"
+	}
+
+	price, msg := validatePrice(&p, field)
+	if msg != "" {
+		return nil, false, msg
+	}
+
+	return price, false, ""
+}
+
+// validatePrice checks that the amount is between 0 and money.MaxAmount and
+// the currency is a known ISO 4217 code. Messages name the request field.
+// A nil price is valid.
+func validatePrice(p *entity.Price, field string) (*entity.Price, string) {
+	if p == nil {
+		return nil, ""
+	}
+
+	if p.Amount < 0 {
+		return nil, field + " amount must not be negative"
+	}
+	if p.Amount > money.MaxAmount {
+		return nil, field + " amount must be at most " + strconv.FormatInt(money.MaxAmount, 10)
+	}
+
+	currency, ok := money.Normalize(p.Currency)
+	if !ok {
+		return nil, field + " currency must be an ISO 4217 code"
+	}
+
+	return &entity.Price{Amount: p.Amount, Currency: currency}, ""
 }
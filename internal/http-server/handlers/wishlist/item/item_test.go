@@ -0,0 +1,491 @@
+package item_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/handlers/wishlist/item"
+	"wish_list/internal/http-server/middleware/auth"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/money"
+	"wish_list/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeItems keeps wishlist owners and items in memory.
+type fakeItems struct {
+	listOwner map[int]int
+	items     map[int]entity.GiftList
+}
+
+func newFakeItems() *fakeItems {
+	return &fakeItems{
+		listOwner: map[int]int{1: 100, 2: 200},
+		items: map[int]entity.GiftList{
+			10: {
+				GiftId:      10,
+				WishListId:  1,
+				Name:        "ten",
+				Url:         "https://example.com/ten",
+				Description: "soft",
+				ImageUrl:    "https://example.com/ten.jpg",
+				Options:     entity.Options{"size": "S"},
+				Price:       &entity.Price{Amount: 1000, Currency: "USD"},
+			},
+			20: {GiftId: 20, WishListId: 2, Name: "twenty", Url: "https://example.com/twenty"},
+		},
+	}
+}
+
+func (f *fakeItems) checkUrl(wishListId, exceptId int, url string) error {
+	for id, gift := range f.items {
+		if gift.WishListId == wishListId && id != exceptId && gift.Url == url {
+			return storage.ErrItemExists
+		}
+	}
+	return nil
+}
+
+func (f *fakeItems) checkList(wishListId, uid int) error {
+	owner, ok := f.listOwner[wishListId]
+	if !ok {
+		return storage.ErrListNotFound
+	}
+	if owner != uid {
+		return storage.ErrForbidden
+	}
+	return nil
+}
+
+func (f *fakeItems) CreateItem(_ context.Context, wishlistId, uid int, gift entity.GiftCreate) (int, error) {
+	if err := f.checkList(wishlistId, uid); err != nil {
+		return 0, fmt.Errorf("fake: %w", err)
+	}
+	if err := f.checkUrl(wishlistId, 0, gift.Url); err != nil {
+		return 0, fmt.Errorf("fake: %w", err)
+	}
+	id := len(f.items) + 100
+	f.items[id] = entity.GiftList{
+		GiftId:      id,
+		WishListId:  wishlistId,
+		Name:        gift.Name,
+		Url:         gift.Url,
+		Description: gift.Description,
+		ImageUrl:    gift.ImageUrl,
+		Options:     gift.Options,
+		Price:       gift.Price,
+		Quantity:    gift.Quantity,
+		Priority:    gift.Priority,
+	}
+	return id, nil
+}
+
+func (f *fakeItems) GetByWishId(_ context.Context, wishListId, uid int) ([]entity.GiftList, error) {
+	if err := f.checkList(wishListId, uid); err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	var list []entity.GiftList
+	for _, gift := range f.items {
+		if gift.WishListId == wishListId {
+			list = append(list, gift)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeItems) DelItemById(_ context.Context, itemId, uid int) error {
+	gift, ok := f.items[itemId]
+	if !ok {
+		return storage.ErrItemNotFound
+	}
+	if err := f.checkList(gift.WishListId, uid); err != nil {
+		return fmt.Errorf("fake: %w", err)
+	}
+	delete(f.items, itemId)
+	return nil
+}
+
+func (f *fakeItems) UpdateItem(_ context.Context, itemId, uid int, upd entity.GiftUpdate) (entity.GiftList, error) {
+	gift, ok := f.items[itemId]
+	if !ok {
+		return entity.GiftList{}, storage.ErrItemNotFound
+	}
+	if err := f.checkList(gift.WishListId, uid); err != nil {
+		return entity.GiftList{}, fmt.Errorf("fake: %w", err)
+	}
+	if upd.Url != nil {
+		if err := f.checkUrl(gift.WishListId, itemId, *upd.Url); err != nil {
+			return entity.GiftList{}, fmt.Errorf("fake: %w", err)
+		}
+		gift.Url = *upd.Url
+	}
+	if upd.Name != nil {
+		gift.Name = *upd.Name
+	}
+	if upd.Description != nil {
+		gift.Description = *upd.Description
+	}
+	if upd.ImageUrl != nil {
+		gift.ImageUrl = *upd.ImageUrl
+	}
+	if upd.Options != nil {
+		gift.Options = *upd.Options
+	}
+	if upd.Price != nil || upd.ClearPrice {
+		gift.Price = upd.Price
+	}
+	if upd.PriceAlert != nil || upd.ClearAlert {
+		gift.PriceAlert = upd.PriceAlert
+	}
+	if upd.Quantity != nil {
+		gift.Quantity = *upd.Quantity
+	}
+	if upd.Priority != nil {
+		gift.Priority = *upd.Priority
+	}
+	f.items[itemId] = gift
+	return gift, nil
+}
+
+func (f *fakeItems) ReorderItems(_ context.Context, wishListId, uid int, giftIds []int) error {
+	if err := f.checkList(wishListId, uid); err != nil {
+		return fmt.Errorf("fake: %w", err)
+	}
+	return nil
+}
+
+func newRouter(store *fakeItems) http.Handler {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	r := chi.NewRouter()
+	r.Post("/api/items/add", item.Create(log, store, nil))
+	r.Get("/api/wishlist/{wishlistId}/items", item.GetByWishId(log, store, nil))
+	r.Post("/api/item/delete", item.Delete(log, store))
+	r.Patch("/api/items/{id}", item.Update(log, store, nil))
+
+	return r
+}
+
+func serve(t *testing.T, h http.Handler, uid int, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{UID: uid}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func do(t *testing.T, h http.Handler, uid int, method, path, body string) int {
+	t.Helper()
+
+	return serve(t, h, uid, method, path, body).Code
+}
+
+func TestOwnership(t *testing.T) {
+	cases := []struct {
+		name   string
+		uid    int
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"create in own list", 100, http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u"}`, http.StatusOK},
+		{"create in foreign list", 100, http.MethodPost, "/api/items/add", `{"wish_list_id":2,"gift_name":"a","url":"https://example.com/u"}`, http.StatusForbidden},
+		{"create in missing list", 100, http.MethodPost, "/api/items/add", `{"wish_list_id":3,"gift_name":"a","url":"https://example.com/u"}`, http.StatusNotFound},
+		{"read own list", 100, http.MethodGet, "/api/wishlist/1/items", "", http.StatusOK},
+		{"read foreign list", 100, http.MethodGet, "/api/wishlist/2/items", "", http.StatusForbidden},
+		{"read missing list", 100, http.MethodGet, "/api/wishlist/3/items", "", http.StatusNotFound},
+		{"delete foreign item", 100, http.MethodPost, "/api/item/delete", `{"item_id":20}`, http.StatusForbidden},
+		{"delete missing item", 100, http.MethodPost, "/api/item/delete", `{"item_id":30}`, http.StatusNotFound},
+		{"update foreign item", 100, http.MethodPatch, "/api/items/20", `{"gift_name":"b"}`, http.StatusForbidden},
+		{"update missing item", 100, http.MethodPatch, "/api/items/30", `{"gift_name":"b"}`, http.StatusNotFound},
+		{"update own item", 100, http.MethodPatch, "/api/items/10", `{"gift_name":"b"}`, http.StatusOK},
+		{"delete own item", 100, http.MethodPost, "/api/item/delete", `{"item_id":10}`, http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeItems()
+
+			if got := do(t, newRouter(store), tc.uid, tc.method, tc.path, tc.body); got != tc.want {
+				t.Fatalf("status = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeleteForeignItemKeepsItem(t *testing.T) {
+	store := newFakeItems()
+
+	do(t, newRouter(store), 100, http.MethodPost, "/api/item/delete", `{"item_id":20}`)
+
+	if _, ok := store.items[20]; !ok {
+		t.Fatal("item of another user was deleted")
+	}
+}
+
+// validationCase is a request by the owner of wishlist 1 and the status it
+// must get. On success stored inspects the created or updated item as the
+// store keeps it; on failure the store must be left as it was.
+type validationCase struct {
+	name   string
+	method string
+	path   string
+	body   string
+	want   int
+	stored func(t *testing.T, gift entity.GiftList)
+}
+
+func runValidation(t *testing.T, cases []validationCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newFakeItems()
+
+			rr := serve(t, newRouter(store), 100, tc.method, tc.path, tc.body)
+			if rr.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tc.want)
+			}
+
+			if rr.Code != http.StatusOK {
+				if want := newFakeItems().items; !reflect.DeepEqual(store.items, want) {
+					t.Fatalf("rejected request changed the store: %+v", store.items)
+				}
+				return
+			}
+
+			var got item.Response
+			if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			gift, ok := store.items[got.GiftId]
+			if !ok {
+				t.Fatalf("item %d is not stored", got.GiftId)
+			}
+			if tc.stored != nil {
+				tc.stored(t, gift)
+			}
+		})
+	}
+}
+
+// price returns a check that the item has the given price, or none.
+func price(want *entity.Price) func(t *testing.T, gift entity.GiftList) {
+	return func(t *testing.T, gift entity.GiftList) {
+		t.Helper()
+
+		if !reflect.DeepEqual(gift.Price, want) {
+			t.Fatalf("price = %+v, want %+v", gift.Price, want)
+		}
+	}
+}
+
+// keeps returns a check that the item 10 differs from its initial state
+// only as change makes it.
+func keeps(change func(gift *entity.GiftList)) func(t *testing.T, gift entity.GiftList) {
+	return func(t *testing.T, gift entity.GiftList) {
+		t.Helper()
+
+		want := newFakeItems().items[10]
+		change(&want)
+		if !reflect.DeepEqual(gift, want) {
+			t.Fatalf("stored %+v, want %+v", gift, want)
+		}
+	}
+}
+
+func TestNameValidation(t *testing.T) {
+	name := func(want string) func(t *testing.T, gift entity.GiftList) {
+		return func(t *testing.T, gift entity.GiftList) {
+			if gift.Name != want {
+				t.Fatalf("name = %q, want %q", gift.Name, want)
+			}
+		}
+	}
+
+	runValidation(t, []validationCase{
+		{"create with padded name", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"  kettle ","url":"https://example.com/u"}`, http.StatusOK,
+			name("kettle")},
+		{"create without name", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"   ","url":"https://example.com/u"}`, http.StatusOK,
+			name("")},
+		{"create with longest name", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"` + strings.Repeat("ж", 200) + `","url":"https://example.com/u"}`, http.StatusOK,
+			name(strings.Repeat("ж", 200))},
+		{"create with long name", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"` + strings.Repeat("a", 201) + `","url":"https://example.com/u"}`, http.StatusBadRequest, nil},
+		{"set padded name", http.MethodPatch, "/api/items/10", `{"gift_name":" b "}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) { g.Name = "b" })},
+		{"set empty name", http.MethodPatch, "/api/items/10", `{"gift_name":" "}`, http.StatusBadRequest, nil},
+	})
+}
+
+func TestPriceValidation(t *testing.T) {
+	runValidation(t, []validationCase{
+		{"create with price", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","price":{"amount":129900,"currency":"rub"}}`, http.StatusOK,
+			price(&entity.Price{Amount: 129900, Currency: "RUB"})},
+		{"create without price", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u"}`, http.StatusOK,
+			price(nil)},
+		{"create with unknown currency", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","price":{"amount":100,"currency":"XYZ"}}`, http.StatusBadRequest, nil},
+		{"create with negative price", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","price":{"amount":-1,"currency":"USD"}}`, http.StatusBadRequest, nil},
+		{"create with largest price", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","price":{"amount":1000000000000,"currency":"USD"},"quantity":999}`, http.StatusOK,
+			price(&entity.Price{Amount: money.MaxAmount, Currency: "USD"})},
+		{"create with too large price", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","price":{"amount":1000000000001,"currency":"USD"}}`, http.StatusBadRequest, nil},
+		{"set too large price alert", http.MethodPatch, "/api/items/10", `{"price_alert":{"amount":1000000000001,"currency":"USD"}}`, http.StatusBadRequest, nil},
+		{"create with float price", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","price":{"amount":12.5,"currency":"USD"}}`, http.StatusBadRequest, nil},
+		{"set price", http.MethodPatch, "/api/items/10", `{"price":{"amount":500,"currency":"eur"}}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) { g.Price = &entity.Price{Amount: 500, Currency: "EUR"} })},
+		{"clear price", http.MethodPatch, "/api/items/10", `{"price":null}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) { g.Price = nil })},
+		{"omit price", http.MethodPatch, "/api/items/10", `{"gift_name":"b"}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) { g.Name = "b" })},
+		{"set invalid price", http.MethodPatch, "/api/items/10", `{"price":{"amount":500}}`, http.StatusBadRequest, nil},
+	})
+}
+
+func TestPriceErrorsNameTheField(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{`{"price":{"amount":-1,"currency":"USD"}}`, "price amount must not be negative"},
+		{`{"price_alert":{"amount":-1,"currency":"USD"}}`, "price_alert amount must not be negative"},
+		{`{"price_alert":{"amount":1000000000001,"currency":"USD"}}`, "price_alert amount must be at most 1000000000000"},
+		{`{"price_alert":{"amount":100,"currency":"XYZ"}}`, "price_alert currency must be an ISO 4217 code"},
+		{`{"price_alert":"cheap"}`, "price_alert must be an object with amount and currency"},
+	}
+
+	for _, tc := range cases {
+		rr := serve(t, newRouter(newFakeItems()), 100, http.MethodPatch, "/api/items/10", tc.body)
+
+		var body resp.Response
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Code != http.StatusBadRequest || body.Error != tc.want {
+			t.Errorf("%s: status %d, error %q; want 400, %q", tc.body, rr.Code, body.Error, tc.want)
+		}
+	}
+}
+
+func TestDetailsValidation(t *testing.T) {
+	long := strings.Repeat("a", 2001)
+	manyOptions := make(map[string]string)
+	for i := 0; i < 21; i++ {
+		manyOptions[fmt.Sprintf("k%d", i)] = "v"
+	}
+	many, _ := json.Marshal(map[string]any{"options": manyOptions})
+
+	runValidation(t, []validationCase{
+		{"create with details", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","description":" warm ","image_url":"https://example.com/a.jpg","options":{"size":"M","color":"red"}}`, http.StatusOK,
+			func(t *testing.T, gift entity.GiftList) {
+				if gift.Description != "warm" || gift.ImageUrl != "https://example.com/a.jpg" || !reflect.DeepEqual(gift.Options, entity.Options{"size": "M", "color": "red"}) {
+					t.Fatalf("stored %+v", gift)
+				}
+			}},
+		{"create with long description", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","description":"` + long + `"}`, http.StatusBadRequest, nil},
+		{"create with relative image", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","image_url":"/a.jpg"}`, http.StatusBadRequest, nil},
+		{"create with ftp image", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","image_url":"ftp://example.com/a.jpg"}`, http.StatusBadRequest, nil},
+		{"create with empty option name", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","options":{" ":"M"}}`, http.StatusBadRequest, nil},
+		{"create with non-string option", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/u","options":{"size":42}}`, http.StatusBadRequest, nil},
+		{"set options", http.MethodPatch, "/api/items/10", `{"options":{"size":"L"}}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) { g.Options = entity.Options{"size": "L"} })},
+		{"clear image", http.MethodPatch, "/api/items/10", `{"image_url":""}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) { g.ImageUrl = "" })},
+		{"set description", http.MethodPatch, "/api/items/10", `{"description":"warm"}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) { g.Description = "warm" })},
+		{"set too many options", http.MethodPatch, "/api/items/10", string(many), http.StatusBadRequest, nil},
+	})
+}
+
+func TestUrlValidation(t *testing.T) {
+	runValidation(t, []validationCase{
+		{"create without url", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a"}`, http.StatusBadRequest, nil},
+		{"create with empty url", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"  "}`, http.StatusBadRequest, nil},
+		{"create with javascript url", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"javascript:alert(1)"}`, http.StatusBadRequest, nil},
+		{"create with relative url", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"/shop/a"}`, http.StatusBadRequest, nil},
+		{"create with long url", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/` + strings.Repeat("a", 2048) + `"}`, http.StatusBadRequest, nil},
+		{"create duplicate", http.MethodPost, "/api/items/add", `{"wish_list_id":1,"gift_name":"a","url":"https://EXAMPLE.com/ten?utm_source=mail"}`, http.StatusConflict, nil},
+		{"set javascript url", http.MethodPatch, "/api/items/10", `{"url":"javascript:alert(1)"}`, http.StatusBadRequest, nil},
+		{"set same url", http.MethodPatch, "/api/items/10", `{"url":"https://example.com/ten?fbclid=x"}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) {})},
+		{"set other url", http.MethodPatch, "/api/items/10", `{"url":"HTTPS://Example.com/eleven?utm_medium=x"}`, http.StatusOK,
+			keeps(func(g *entity.GiftList) { g.Url = "https://example.com/eleven" })},
+	})
+}
+
+func TestCreateNormalizesUrl(t *testing.T) {
+	store := newFakeItems()
+	h := newRouter(store)
+
+	body := `{"wish_list_id":2,"gift_name":"a","url":"HTTPS://Shop.Example.com:443/p/1?utm_campaign=sale&color=red&gclid=42"}`
+	if code := do(t, h, 200, http.MethodPost, "/api/items/add", body); code != http.StatusOK {
+		t.Fatalf("create: status %d", code)
+	}
+
+	var stored []string
+	for id, gift := range store.items {
+		if gift.WishListId == 2 && id != 20 {
+			stored = append(stored, gift.Url)
+		}
+	}
+	if want := "https://shop.example.com/p/1?color=red"; len(stored) != 1 || stored[0] != want {
+		t.Fatalf("stored %v, want %q", stored, want)
+	}
+
+	// The same list may not hold the link twice, other lists may.
+	if code := do(t, h, 200, http.MethodPost, "/api/items/add", body); code != http.StatusConflict {
+		t.Fatalf("duplicate: status %d, want 409", code)
+	}
+	if code := do(t, h, 100, http.MethodPost, "/api/items/add", strings.Replace(body, `"wish_list_id":2`, `"wish_list_id":1`, 1)); code != http.StatusOK {
+		t.Fatalf("other list: status %d", code)
+	}
+}
+
+// fakeEnricher records the items queued for enrichment.
+type fakeEnricher []int
+
+func (f *fakeEnricher) Enqueue(itemId int, url string) bool {
+	*f = append(*f, itemId)
+	return true
+}
+
+func TestCreateEnqueuesEnrichment(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"missing details", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/a"}`, true},
+		{"missing name", `{"wish_list_id":1,"url":"https://example.com/a","image_url":"https://example.com/a.jpg","price":{"amount":100,"currency":"USD"}}`, true},
+		{"complete", `{"wish_list_id":1,"gift_name":"a","url":"https://example.com/a","image_url":"https://example.com/a.jpg","price":{"amount":100,"currency":"USD"}}`, false},
+		{"no url", `{"wish_list_id":1,"gift_name":"a"}`, false},
+		{"foreign list", `{"wish_list_id":2,"url":"https://example.com/a"}`, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var enricher fakeEnricher
+
+			r := chi.NewRouter()
+			r.Post("/api/items/add", item.Create(slog.New(slog.NewTextHandler(io.Discard, nil)), newFakeItems(), &enricher))
+			do(t, r, 100, http.MethodPost, "/api/items/add", tc.body)
+
+			if got := len(enricher) == 1; got != tc.want {
+				t.Fatalf("enqueued %v, want %v", enricher, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"wish_list/internal/http-server/middleware/auth"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/storage"
+)
+
+// TransferRequest names the items to move or copy and the wishlist they go
+// to. The items are added after the wishlist's own items, in the given
+// order.
+type TransferRequest struct {
+	WishListId int   `json:"wish_list_id"`
+	GiftIds    []int `json:"gift_ids"`
+}
+
+// CopyResponse holds the ids of the copies in the order of the request.
+type CopyResponse struct {
+	GiftIds []int `json:"gift_ids"`
+}
+
+const maxTransferItems = 100
+
+type Transfer interface {
+	MoveItems(ctx context.Context, wishListId, uid int, giftIds []int) error
+	CopyItems(ctx context.Context, wishListId, uid int, giftIds []int) ([]int, error)
+}
+
+// Move moves items of the user to another of their wishlists. Reservations
+// and pledges stay with the items. Either all items are moved or none.
+func Move(log *slog.Logger, transfer Transfer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.item.Move"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, req, ok := decodeTransfer(log, w, r)
+		if !ok {
+			return
+		}
+
+		err := transfer.MoveItems(r.Context(), req.WishListId, uid, req.GiftIds)
+		if err != nil {
+			transferError(log, w, r, err)
+			return
+		}
+
+		log.Info("items moved", slog.Int("wish_list_id", req.WishListId))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+// Copy copies items of the user into another of their wishlists. The copies
+// start without reservations, pledges or price history. Either all items
+// are copied or none.
+func Copy(log *slog.Logger, transfer Transfer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.item.Copy"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, req, ok := decodeTransfer(log, w, r)
+		if !ok {
+			return
+		}
+
+		ids, err := transfer.CopyItems(r.Context(), req.WishListId, uid, req.GiftIds)
+		if err != nil {
+			transferError(log, w, r, err)
+			return
+		}
+
+		log.Info("items copied", slog.Int("wish_list_id", req.WishListId), slog.Any("gift_ids", ids))
+
+		render.JSON(w, r, CopyResponse{GiftIds: ids})
+	}
+}
+
+// decodeTransfer reads and validates the request of Move and Copy. It
+// writes the response itself when the request is not acceptable.
+func decodeTransfer(log *slog.Logger, w http.ResponseWriter, r *http.Request) (int, TransferRequest, bool) {
+	var req TransferRequest
+
+	uid, ok := auth.UIDFromContext(r.Context())
+	if !ok {
+		log.Error("uid is missing in request context")
+		w.WriteHeader(http.StatusUnauthorized)
+		render.JSON(w, r, resp.Error("unauthorized"))
+		return 0, req, false
+	}
+
+	err := render.DecodeJSON(r.Body, &req)
+	if errors.Is(err, io.EOF) {
+		log.Error("request body is empty")
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("empty request"))
+		return 0, req, false
+	}
+	if err != nil {
+		log.Error("failed to decode request body", sl.Err(err))
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("failed to decode request"))
+		return 0, req, false
+	}
+
+	log.Info("request body decoded", slog.Any("request", req))
+
+	if msg := req.validate(); msg != "" {
+		log.Info("invalid request", slog.String("reason", msg))
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, resp.Error(msg))
+		return 0, req, false
+	}
+
+	return uid, req, true
+}
+
+// validate returns a message for the client when the request is not
+// acceptable.
+func (req TransferRequest) validate() string {
+	if req.WishListId <= 0 {
+		return "wish_list_id is required"
+	}
+	if len(req.GiftIds) == 0 || len(req.GiftIds) > maxTransferItems {
+		return "gift_ids must list between 1 and 100 items"
+	}
+
+	seen := make(map[int]bool, len(req.GiftIds))
+	for _, id := range req.GiftIds {
+		if id <= 0 || seen[id] {
+			return "gift_ids must list distinct item ids"
+		}
+		seen[id] = true
+	}
+
+	return ""
+}
+
+// transferError answers a failed Move or Copy.
+func transferError(log *slog.Logger, w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, storage.ErrListNotFound) {
+		log.Info("wishlist not found")
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, resp.Error("wishlist not found"))
+		return
+	}
+	if errors.Is(err, storage.ErrItemNotFound) {
+		log.Info("item not found")
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, resp.Error("item not found"))
+		return
+	}
+	if errors.Is(err, storage.ErrForbidden) {
+		log.Info("access denied")
+		w.WriteHeader(http.StatusForbidden)
+		render.JSON(w, r, resp.Error("access denied"))
+		return
+	}
+	if errors.Is(err, storage.ErrItemExists) {
+		log.Info("duplicate item url")
+		w.WriteHeader(http.StatusConflict)
+		render.JSON(w, r, resp.Error(duplicateUrl))
+		return
+	}
+
+	log.Error("failed to transfer items", sl.Err(err))
+	w.WriteHeader(http.StatusInternalServerError)
+	render.JSON(w, r, resp.Error("internal error"))
+}
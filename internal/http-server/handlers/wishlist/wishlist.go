@@ -1,18 +1,25 @@
 package wishlist
 
 import (
+	"context"
 	"errors"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
 	"io"
 	"log/slog"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 	"wish_list/internal/entity"
-	"wish_list/internal/http-server/handlers/auth/uidextractor"
+	"wish_list/internal/http-server/middleware/auth"
 	alias2 "wish_list/internal/lib/alias"
 	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/storage"
 )
 
 type Request struct {
@@ -25,10 +32,30 @@ type Response struct {
 	Alias      string `json:"alias"`
 }
 
+// UpdateRequest holds the fields of a partial wishlist update; omitted
+// fields keep their current values. An empty event_date clears it.
+type UpdateRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	EventDate   *string `json:"event_date"`
+	CoverEmoji  *string `json:"cover_emoji"`
+	CoverColor  *string `json:"cover_color"`
+	Surprise    *bool   `json:"surprise"`
+}
+
+const (
+	maxNameLen        = 200
+	maxDescriptionLen = 2000
+	maxEmojiLen       = 16
+)
+
+var coverColorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
 type Wishlist interface {
-	CreateList(name, alias string, uid int) (int, error)
-	GetAllLists(uid int) ([]entity.WishList, error)
-	WishListDel(wishListId int) error
+	CreateList(ctx context.Context, name, alias string, uid int) (int, error)
+	GetAllLists(ctx context.Context, uid int) ([]entity.WishList, error)
+	WishListDel(ctx context.Context, wishListId, uid int) error
+	UpdateList(ctx context.Context, wishListId, uid int, upd entity.WishListUpdate) (entity.WishList, error)
 }
 
 func Create(log *slog.Logger, wishlist Wishlist) http.HandlerFunc {
@@ -40,38 +67,17 @@ func Create(log *slog.Logger, wishlist Wishlist) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
-			return
-		}
-
-		splitToken := strings.Split(authHeader, "Bearer ")
-		if len(splitToken) != 2 {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := splitToken[1]
-
-		uid, token, err := uidextractor.ValidateToken(tokenString)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
-			return
-		}
-
-		_ = token
-
-		uidInt, err := strconv.Atoi(uid)
-		if err != nil {
-			log.Error("Invalid UID format: ", err)
-			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
 		var req Request
 
-		err = render.DecodeJSON(r.Body, &req)
+		err := render.DecodeJSON(r.Body, &req)
 		if errors.Is(err, io.EOF) {
 			log.Error("request body is empty")
 
@@ -80,7 +86,7 @@ func Create(log *slog.Logger, wishlist Wishlist) http.HandlerFunc {
 			return
 		}
 		if err != nil {
-			log.Error("failed to decode request body: ", err)
+			log.Error("failed to decode request body", sl.Err(err))
 
 			render.JSON(w, r, resp.Error("failed to decode request"))
 
@@ -89,11 +95,11 @@ func Create(log *slog.Logger, wishlist Wishlist) http.HandlerFunc {
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		alias := alias2.NewRandomString(uidInt)
+		alias := alias2.NewRandomString(uid)
 
-		wishListId, err := wishlist.CreateList(req.Name, alias, uidInt)
+		wishListId, err := wishlist.CreateList(r.Context(), req.Name, alias, uid)
 		if err != nil {
-			log.Error("failed to create wishlist: ", err)
+			log.Error("failed to create wishlist", sl.Err(err))
 
 			render.JSON(w, r, resp.Error("internal error"))
 
@@ -120,38 +126,17 @@ func GetAllLists(log *slog.Logger, wishlist Wishlist) http.HandlerFunc {
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
-			return
-		}
-
-		splitToken := strings.Split(authHeader, "Bearer ")
-		if len(splitToken) != 2 {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
-		tokenString := splitToken[1]
-
-		uid, token, err := uidextractor.ValidateToken(tokenString)
+		lists, err := wishlist.GetAllLists(r.Context(), uid)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
-			return
-		}
-
-		_ = token
-
-		uidInt, err := strconv.Atoi(uid)
-		if err != nil {
-			log.Error("Invalid UID format: ", err)
-			http.Error(w, "Invalid user ID", http.StatusBadRequest)
-			return
-		}
-
-		lists, err := wishlist.GetAllLists(uidInt)
-		if err != nil {
-			log.Error("failed to get wishlists: ", err)
+			log.Error("failed to get wishlists", sl.Err(err))
 
 			render.JSON(w, r, resp.Error("internal error"))
 
@@ -171,67 +156,198 @@ type RequestForDel struct {
 
 func Delete(log *slog.Logger, wishlist Wishlist) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		const op = "handlers.wishlist.Create"
+		const op = "handlers.wishlist.Delete"
 
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
 		)
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
-		splitToken := strings.Split(authHeader, "Bearer ")
-		if len(splitToken) != 2 {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
+		var req RequestForDel
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
 			return
 		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
 
-		tokenString := splitToken[1]
+			render.JSON(w, r, resp.Error("failed to decode request"))
 
-		uid, token, err := uidextractor.ValidateToken(tokenString)
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		err = wishlist.WishListDel(r.Context(), req.WishListId, uid)
+		if errors.Is(err, storage.ErrListNotFound) {
+			log.Info("wishlist not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("wishlist not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("access denied")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			log.Error("failed to delete wishlist", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("internal error"))
+
+			return
+		}
+
+		log.Info("wishlist deleted")
+
+		render.JSON(w, r, http.StatusOK)
+
+	}
+}
+
+func Update(log *slog.Logger, wishlist Wishlist) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.wishlist.Update"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			log.Error("uid is missing in request context")
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("unauthorized"))
 			return
 		}
 
-		_, _ = token, uid
+		wishListId, err := strconv.Atoi(chi.URLParam(r, "wishlistId"))
+		if err != nil {
+			log.Info("invalid wishlist id", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid wishlist id"))
+			return
+		}
 
-		var req RequestForDel
+		var req UpdateRequest
 
 		err = render.DecodeJSON(r.Body, &req)
 		if errors.Is(err, io.EOF) {
 			log.Error("request body is empty")
-
+			w.WriteHeader(http.StatusBadRequest)
 			render.JSON(w, r, resp.Error("empty request"))
-
 			return
 		}
 		if err != nil {
-			log.Error("failed to decode request body: ", err)
-
+			log.Error("failed to decode request body", sl.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
 			render.JSON(w, r, resp.Error("failed to decode request"))
-
 			return
 		}
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		err = wishlist.WishListDel(req.WishListId)
-		if err != nil {
-			log.Error("failed to delete wishlist: ", err)
+		upd, msg := req.toUpdate()
+		if msg != "" {
+			log.Info("invalid update", slog.String("reason", msg))
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(msg))
+			return
+		}
 
+		updated, err := wishlist.UpdateList(r.Context(), wishListId, uid, upd)
+		if errors.Is(err, storage.ErrListNotFound) {
+			log.Info("wishlist not found")
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, resp.Error("wishlist not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrForbidden) {
+			log.Info("access denied")
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.Error("access denied"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to update wishlist", sl.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
 			render.JSON(w, r, resp.Error("internal error"))
-
 			return
 		}
 
-		log.Info("wishlist deleted")
+		log.Info("wishlist updated")
 
-		render.JSON(w, r, http.StatusOK)
+		render.JSON(w, r, updated)
+	}
+}
 
+// toUpdate validates the supplied fields and returns a message for the
+// client when they are not acceptable.
+func (req UpdateRequest) toUpdate() (entity.WishListUpdate, string) {
+	var upd entity.WishListUpdate
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLen {
+			return upd, "name must be between 1 and 200 characters"
+		}
+		upd.Name = &name
+	}
+
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if utf8.RuneCountInString(description) > maxDescriptionLen {
+			return upd, "description must be at most 2000 characters"
+		}
+		upd.Description = &description
 	}
+
+	if req.EventDate != nil {
+		date := strings.TrimSpace(*req.EventDate)
+		if date != "" {
+			if _, err := time.Parse(time.DateOnly, date); err != nil {
+				return upd, "event_date must be a date in YYYY-MM-DD format"
+			}
+		}
+		upd.EventDate = &date
+	}
+
+	if req.CoverEmoji != nil {
+		emoji := strings.TrimSpace(*req.CoverEmoji)
+		if utf8.RuneCountInString(emoji) > maxEmojiLen {
+			return upd, "cover_emoji is too long"
+		}
+		upd.CoverEmoji = &emoji
+	}
+
+	if req.CoverColor != nil {
+		color := strings.TrimSpace(*req.CoverColor)
+		if color != "" && !coverColorRe.MatchString(color) {
+			return upd, "cover_color must be a hex color like #ff8800"
+		}
+		upd.CoverColor = &color
+	}
+
+	upd.Surprise = req.Surprise
+
+	if upd == (entity.WishListUpdate{}) {
+		return upd, "nothing to update"
+	}
+
+	return upd, ""
 }
@@ -0,0 +1,130 @@
+package wishlist_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/handlers/wishlist"
+	"wish_list/internal/http-server/middleware/auth"
+	"wish_list/internal/storage"
+)
+
+type fakeLists struct {
+	owner map[int]int
+}
+
+func (f *fakeLists) CreateList(_ context.Context, name, alias string, uid int) (int, error) {
+	id := len(f.owner) + 1
+	f.owner[id] = uid
+	return id, nil
+}
+
+func (f *fakeLists) GetAllLists(_ context.Context, uid int) ([]entity.WishList, error) {
+	return nil, nil
+}
+
+func (f *fakeLists) WishListDel(_ context.Context, wishListId, uid int) error {
+	owner, ok := f.owner[wishListId]
+	if !ok {
+		return storage.ErrListNotFound
+	}
+	if owner != uid {
+		return storage.ErrForbidden
+	}
+	delete(f.owner, wishListId)
+	return nil
+}
+
+func (f *fakeLists) UpdateList(_ context.Context, wishListId, uid int, upd entity.WishListUpdate) (entity.WishList, error) {
+	owner, ok := f.owner[wishListId]
+	if !ok {
+		return entity.WishList{}, storage.ErrListNotFound
+	}
+	if owner != uid {
+		return entity.WishList{}, storage.ErrForbidden
+	}
+	return entity.WishList{UID: uid}, nil
+}
+
+func TestDeleteOwnership(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cases := []struct {
+		name string
+		uid  int
+		body string
+		want int
+	}{
+		{"own list", 100, `{"wish_list_id":1}`, http.StatusOK},
+		{"foreign list", 200, `{"wish_list_id":1}`, http.StatusForbidden},
+		{"missing list", 100, `{"wish_list_id":5}`, http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeLists{owner: map[int]int{1: 100}}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/wishlist/delete", bytes.NewBufferString(tc.body))
+			req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{UID: tc.uid}))
+			rr := httptest.NewRecorder()
+
+			wishlist.Delete(log, store).ServeHTTP(rr, req)
+
+			if rr.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tc.want)
+			}
+			if tc.want != http.StatusOK {
+				if _, ok := store.owner[1]; !ok {
+					t.Fatal("wishlist was deleted by a non-owner")
+				}
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cases := []struct {
+		name string
+		uid  int
+		path string
+		body string
+		want int
+	}{
+		{"own list", 100, "/1", `{"name":"new year","cover_color":"#ff8800"}`, http.StatusOK},
+		{"clear event date", 100, "/1", `{"event_date":""}`, http.StatusOK},
+		{"foreign list", 200, "/1", `{"name":"mine now"}`, http.StatusForbidden},
+		{"missing list", 100, "/5", `{"name":"x"}`, http.StatusNotFound},
+		{"bad id", 100, "/abc", `{"name":"x"}`, http.StatusBadRequest},
+		{"empty name", 100, "/1", `{"name":"  "}`, http.StatusBadRequest},
+		{"bad date", 100, "/1", `{"event_date":"2024-13-40"}`, http.StatusBadRequest},
+		{"bad color", 100, "/1", `{"cover_color":"red"}`, http.StatusBadRequest},
+		{"nothing", 100, "/1", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeLists{owner: map[int]int{1: 100}}
+
+			router := chi.NewRouter()
+			router.Patch("/{wishlistId}", wishlist.Update(log, store))
+
+			req := httptest.NewRequest(http.MethodPatch, tc.path, bytes.NewBufferString(tc.body))
+			req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{UID: tc.uid}))
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/handlers/auth/uidextractor"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/session"
+	"wish_list/internal/storage"
+)
+
+// APITokenPrefix marks personal access tokens, which are looked up in
+// storage instead of being parsed as JWTs.
+const APITokenPrefix = "wlpat_"
+
+// Identity describes the authenticated caller of a request. TokenID and
+// ExpiresAt describe the access token used, so it can be revoked.
+// APITokenID is set when the caller used a personal access token, in which
+// case Scopes limits what the caller may do.
+type Identity struct {
+	UID        int
+	TokenID    string
+	ExpiresAt  time.Time
+	APITokenID int
+	Scopes     []string
+}
+
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, tokenString string) (*uidextractor.Claims, error)
+}
+
+type APITokens interface {
+	UseAPIToken(ctx context.Context, tokenHash string) (entity.APIToken, int, error)
+}
+
+type ctxKey struct{}
+
+const (
+	CodeMissingToken     = "missing_token"
+	CodeInvalidFormat    = "invalid_token_format"
+	CodeMalformedToken   = "malformed_token"
+	CodeInvalidSignature = "invalid_signature"
+	CodeUnknownKey       = "unknown_key"
+	CodeMissingExpiry    = "missing_expiry"
+	CodeTokenExpired     = "token_expired"
+	CodeTokenNotYetValid = "token_not_yet_valid"
+	CodeInvalidIssuer    = "invalid_issuer"
+	CodeInvalidAudience  = "invalid_audience"
+	CodeMissingUID       = "missing_uid"
+	CodeInvalidUID       = "invalid_uid"
+	CodeTokenRevoked     = "token_revoked"
+	CodeInvalidToken     = "invalid_token"
+	CodeAuthUnavailable  = "auth_unavailable"
+)
+
+var rejections = []struct {
+	err  error
+	code string
+}{
+	{uidextractor.ErrMalformedToken, CodeMalformedToken},
+	{uidextractor.ErrInvalidSignature, CodeInvalidSignature},
+	{uidextractor.ErrUnknownKey, CodeUnknownKey},
+	{uidextractor.ErrMissingExpiry, CodeMissingExpiry},
+	{uidextractor.ErrTokenExpired, CodeTokenExpired},
+	{uidextractor.ErrTokenNotYetValid, CodeTokenNotYetValid},
+	{uidextractor.ErrInvalidIssuer, CodeInvalidIssuer},
+	{uidextractor.ErrInvalidAudience, CodeInvalidAudience},
+	{uidextractor.ErrMissingUID, CodeMissingUID},
+	{uidextractor.ErrInvalidUID, CodeInvalidUID},
+	{uidextractor.ErrTokenRevoked, CodeTokenRevoked},
+}
+
+// New returns a middleware that validates the bearer token of every request
+// and stores the caller's Identity in the request context.
+func New(log *slog.Logger, validator TokenValidator, apiTokens APITokens) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		log.Info("auth middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			log := log.With(
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				unauthorized(w, r, CodeMissingToken, "authorization header is missing")
+				return
+			}
+
+			tokenString, ok := strings.CutPrefix(authHeader, "Bearer ")
+			if !ok || tokenString == "" {
+				unauthorized(w, r, CodeInvalidFormat, "invalid token format")
+				return
+			}
+
+			if strings.HasPrefix(tokenString, APITokenPrefix) {
+				t, uid, err := apiTokens.UseAPIToken(r.Context(), session.HashToken(tokenString))
+				if errors.Is(err, storage.ErrTokenNotFound) {
+					unauthorized(w, r, CodeInvalidToken, "invalid api token")
+					return
+				}
+				if err != nil {
+					log.Error("failed to check api token", sl.Err(err))
+					unavailable(w, r)
+					return
+				}
+
+				ctx := WithIdentity(r.Context(), Identity{
+					UID:        uid,
+					APITokenID: t.TokenId,
+					Scopes:     t.Scopes,
+				})
+
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			claims, err := validator.ValidateToken(r.Context(), tokenString)
+			if err != nil {
+				code, msg, ok := rejection(err)
+				if !ok {
+					// The token may well be valid; the client must not
+					// drop its session because the denylist is down.
+					log.Error("failed to validate token", sl.Err(err))
+					unavailable(w, r)
+					return
+				}
+				log.Info("token rejected", sl.Err(err))
+				unauthorized(w, r, code, msg)
+				return
+			}
+
+			id := Identity{
+				UID:     claims.UID,
+				TokenID: claims.ID,
+			}
+			if claims.ExpiresAt != nil {
+				id.ExpiresAt = claims.ExpiresAt.Time
+			}
+
+			ctx := WithIdentity(r.Context(), id)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// WithIdentity returns a copy of ctx carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the Identity stored by the auth middleware.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok
+}
+
+// UIDFromContext is a shorthand for reading the caller's uid.
+func UIDFromContext(ctx context.Context) (int, bool) {
+	id, ok := FromContext(ctx)
+	return id.UID, ok
+}
+
+// rejection maps a validation error to a response code and a message that is
+// safe to show to the client. It reports false for errors that do not
+// reject the token, such as a failed revocation check.
+func rejection(err error) (string, string, bool) {
+	for _, r := range rejections {
+		if errors.Is(err, r.err) {
+			return r.code, r.err.Error(), true
+		}
+	}
+
+	return "", "", false
+}
+
+// unavailable answers a request whose credentials could not be checked.
+func unavailable(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusServiceUnavailable)
+	render.JSON(w, r, resp.ErrorCode(CodeAuthUnavailable, "authentication is temporarily unavailable"))
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, code, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	render.JSON(w, r, resp.ErrorCode(code, msg))
+}
@@ -0,0 +1,174 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"wish_list/internal/config"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/handlers/auth/uidextractor"
+	"wish_list/internal/http-server/middleware/auth"
+	resp "wish_list/internal/lib/api/response"
+	"wish_list/internal/lib/keyset"
+	"wish_list/internal/storage"
+)
+
+const secret = "test-secret"
+
+type noAPITokens struct{}
+
+func (noAPITokens) UseAPIToken(context.Context, string) (entity.APIToken, int, error) {
+	return entity.APIToken{}, 0, storage.ErrTokenNotFound
+}
+
+// brokenStorage fails every lookup like a database that is down.
+type brokenStorage struct{}
+
+func (brokenStorage) UseAPIToken(context.Context, string) (entity.APIToken, int, error) {
+	return entity.APIToken{}, 0, errors.New("connection refused")
+}
+
+func (brokenStorage) IsTokenRevoked(context.Context, string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func newHandler(t *testing.T) http.Handler {
+	t.Helper()
+
+	keys, err := keyset.Load(config.Auth{Keys: []config.Key{{Secret: secret}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	validator := uidextractor.New(keys, uidextractor.Options{Leeway: time.Second})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, ok := auth.UIDFromContext(r.Context())
+		if !ok {
+			t.Error("identity is missing in the request context")
+		}
+		w.Header().Set("X-Uid", strconv.Itoa(uid))
+	})
+
+	return auth.New(log, validator, noAPITokens{})(next)
+}
+
+func sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMiddleware(t *testing.T) {
+	h := newHandler(t)
+	now := time.Now()
+
+	valid := sign(t, jwt.MapClaims{"uid": 7, "exp": now.Add(time.Minute).Unix()})
+	expired := sign(t, jwt.MapClaims{"uid": 7, "exp": now.Add(-time.Minute).Unix()})
+
+	foreign := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"uid": 7, "exp": now.Add(time.Minute).Unix()})
+	foreign.Header["kid"] = "retired"
+	unknownKid, err := foreign.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		header string
+		code   string
+	}{
+		{"missing header", "", auth.CodeMissingToken},
+		{"basic scheme", "Basic dXNlcjpwYXNz", auth.CodeInvalidFormat},
+		{"lowercase scheme", "bearer " + valid, auth.CodeInvalidFormat},
+		{"empty token", "Bearer ", auth.CodeInvalidFormat},
+		{"not a jwt", "Bearer abc.def", auth.CodeMalformedToken},
+		{"expired token", "Bearer " + expired, auth.CodeTokenExpired},
+		{"unknown kid", "Bearer " + unknownKid, auth.CodeUnknownKey},
+		{"unknown api token", "Bearer " + auth.APITokenPrefix + "nope", auth.CodeInvalidToken},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("status %d, want 401", w.Code)
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate header is missing")
+			}
+
+			var body resp.Response
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Status != resp.StatusError || body.Code != tc.code {
+				t.Fatalf("body = %+v, want code %s", body, tc.code)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+valid)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || w.Header().Get("X-Uid") != "7" {
+		t.Fatalf("valid token: status %d, uid %q", w.Code, w.Header().Get("X-Uid"))
+	}
+}
+
+func TestMiddlewareStorageFailure(t *testing.T) {
+	keys, err := keyset.Load(config.Auth{Keys: []config.Key{{Secret: secret}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	validator := uidextractor.New(keys, uidextractor.Options{Denylist: brokenStorage{}})
+	h := auth.New(log, validator, brokenStorage{})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("request passed without a checked token")
+	}))
+
+	tokens := []string{
+		sign(t, jwt.MapClaims{"uid": 7, "exp": time.Now().Add(time.Minute).Unix(), "jti": "live"}),
+		auth.APITokenPrefix + "secret",
+	}
+
+	for _, token := range tokens {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, r)
+
+		var body resp.Response
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusServiceUnavailable || body.Code != auth.CodeAuthUnavailable {
+			t.Fatalf("status %d, body %+v, want 503 %s", w.Code, body, auth.CodeAuthUnavailable)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// GuestTokenHeader carries the signed token of a visitor without an account.
+const GuestTokenHeader = "X-Guest-Token"
+
+const CodeInvalidGuestToken = "invalid_guest_token"
+
+type GuestVerifier interface {
+	Verify(token string) (string, error)
+}
+
+type guestKey struct{}
+
+// Optional is New for endpoints open to anonymous callers: requests
+// without an Authorization header pass through without an Identity.
+func Optional(log *slog.Logger, validator TokenValidator, apiTokens APITokens) func(next http.Handler) http.Handler {
+	required := New(log, validator, apiTokens)
+
+	return func(next http.Handler) http.Handler {
+		authenticated := required(next)
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			authenticated.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Guest stores the guest id from a valid X-Guest-Token header in the
+// request context. Requests without the header pass through.
+func Guest(verifier GuestVerifier) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(GuestTokenHeader)
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			guestId, err := verifier.Verify(token)
+			if err != nil {
+				unauthorized(w, r, CodeInvalidGuestToken, "invalid guest token")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), guestKey{}, guestId)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// GuestFromContext returns the guest id stored by the Guest middleware.
+func GuestFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(guestKey{}).(string)
+	return id, ok
+}
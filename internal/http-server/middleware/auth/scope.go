@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"github.com/go-chi/render"
+	"net/http"
+	"slices"
+	resp "wish_list/internal/lib/api/response"
+)
+
+const (
+	ScopeListsRead  = "lists:read"
+	ScopeListsWrite = "lists:write"
+	ScopeItemsRead  = "items:read"
+	ScopeItemsWrite = "items:write"
+	ScopeShareRead  = "share:read"
+	ScopeShareWrite = "share:write"
+)
+
+const CodeInsufficientScope = "insufficient_scope"
+
+// Scopes lists every scope an API token may be granted.
+var Scopes = []string{
+	ScopeListsRead,
+	ScopeListsWrite,
+	ScopeItemsRead,
+	ScopeItemsWrite,
+	ScopeShareRead,
+	ScopeShareWrite,
+}
+
+// IsAPIToken reports whether the caller authenticated with a personal
+// access token rather than a login session.
+func (id Identity) IsAPIToken() bool {
+	return id.APITokenID != 0
+}
+
+// HasScope reports whether the caller may act within scope. Login sessions
+// are not restricted.
+func (id Identity) HasScope(scope string) bool {
+	if !id.IsAPIToken() {
+		return true
+	}
+	return slices.Contains(id.Scopes, scope)
+}
+
+// RequireScope rejects requests made with an API token lacking scope.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id, ok := FromContext(r.Context())
+			if !ok {
+				unauthorized(w, r, CodeMissingToken, "unauthorized")
+				return
+			}
+
+			if !id.HasScope(scope) {
+				w.WriteHeader(http.StatusForbidden)
+				render.JSON(w, r, resp.ErrorCode(CodeInsufficientScope, "token lacks scope "+scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireSession rejects requests made with an API token. It guards
+// endpoints that manage credentials.
+func RequireSession(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id, ok := FromContext(r.Context())
+		if !ok {
+			unauthorized(w, r, CodeMissingToken, "unauthorized")
+			return
+		}
+
+		if id.IsAPIToken() {
+			w.WriteHeader(http.StatusForbidden)
+			render.JSON(w, r, resp.ErrorCode(CodeInsufficientScope, "api tokens cannot access this endpoint"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
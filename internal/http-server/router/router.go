@@ -0,0 +1,118 @@
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/cors"
+	"log/slog"
+	"net/http"
+	"wish_list/internal/http-server/handlers/apitoken"
+	"wish_list/internal/http-server/handlers/auth/guest"
+	"wish_list/internal/http-server/handlers/auth/sso"
+	"wish_list/internal/http-server/handlers/auth/user"
+	"wish_list/internal/http-server/handlers/outbound"
+	"wish_list/internal/http-server/handlers/reservation"
+	"wish_list/internal/http-server/handlers/sharelist"
+	"wish_list/internal/http-server/handlers/wishlist"
+	"wish_list/internal/http-server/handlers/wishlist/item"
+	"wish_list/internal/http-server/middleware/auth"
+	"wish_list/internal/http-server/middleware/logger"
+	"wish_list/internal/storage"
+)
+
+// Options carries everything the HTTP API depends on.
+type Options struct {
+	Storage   storage.Store
+	Validator auth.TokenValidator
+	Sessions  user.Sessions
+	Guests    GuestTokens
+	// OIDC enables the external login routes when set.
+	OIDC     sso.Provider
+	OIDCName string
+	// Enricher, when set, fills in new items from their web pages.
+	Enricher item.Enricher
+	// Links, when set, rewrites outbound item links into affiliate links.
+	Links Links
+}
+
+// Links rewrites outbound item links, one at a time or for a whole list.
+type Links interface {
+	outbound.Links
+	item.Links
+}
+
+// GuestTokens issues and verifies the tokens of visitors without an account.
+type GuestTokens interface {
+	guest.Issuer
+	auth.GuestVerifier
+}
+
+func New(log *slog.Logger, opts Options) http.Handler {
+	storage := opts.Storage
+	sessions := opts.Sessions
+	links := opts.Links
+
+	router := chi.NewRouter()
+
+	corsHandler := cors.New(cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET", "POST", "DELETE", "PUT", "PATCH", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", auth.GuestTokenHeader},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	})
+
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Logger)
+	router.Use(logger.New(log))
+	router.Use(middleware.Recoverer)
+	router.Use(middleware.URLFormat)
+	router.Use(corsHandler.Handler)
+
+	router.Post("/api/auth/register", user.Register(log, storage, sessions)) // регистрация пользователя
+	router.Post("/api/auth/login", user.Login(log, storage, sessions))       // вход по логину и паролю
+	router.Post("/api/auth/refresh", user.Refresh(log, sessions))            // обновление пары токенов
+	router.Post("/api/guest/token", guest.Token(log, opts.Guests))           // гостевой токен для брони без аккаунта
+	router.Get("/go/{gift_id}", outbound.Redirect(log, storage, links))      // переход по ссылке подарка с подсчётом кликов
+
+	if opts.OIDC != nil {
+		router.Get("/api/auth/oidc/login", sso.Login(log, opts.OIDC))                                         // вход через внешнего провайдера
+		router.Get("/api/auth/oidc/callback", sso.Callback(log, opts.OIDCName, opts.OIDC, storage, sessions)) // возврат от провайдера
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(auth.Optional(log, opts.Validator, storage))
+		r.Use(auth.Guest(opts.Guests))
+
+		r.Get("/api/sharelist/{alias}", sharelist.GetList(log, storage, links))                                              // получение вишлиста по алиасу
+		r.Post("/api/sharelist/{alias}/items/{itemId}/reserve", reservation.Reserve(log, storage))                           // бронирование подарка гостем
+		r.Delete("/api/sharelist/{alias}/items/{itemId}/reserve", reservation.Unreserve(log, storage))                       // отмена брони
+		r.Post("/api/sharelist/{alias}/items/{itemId}/contributions", reservation.Pledge(log, storage))                      // взнос на совместный подарок
+		r.Delete("/api/sharelist/{alias}/items/{itemId}/contributions/{contributionId}", reservation.Withdraw(log, storage)) // отзыв взноса
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(auth.New(log, opts.Validator, storage))
+
+		r.With(auth.RequireSession).Post("/api/auth/logout", user.Logout(log, sessions))           // выход, отзыв токенов
+		r.With(auth.RequireSession).Post("/api/tokens", apitoken.Create(log, storage))             // выпуск персонального токена
+		r.With(auth.RequireSession).Get("/api/tokens", apitoken.List(log, storage))                // список персональных токенов
+		r.With(auth.RequireSession).Delete("/api/tokens/{tokenId}", apitoken.Revoke(log, storage)) // отзыв персонального токена
+
+		r.With(auth.RequireScope(auth.ScopeListsWrite)).Post("/api/wishlist/create", wishlist.Create(log, storage))                   //создание вишлиста в личном кабинете
+		r.With(auth.RequireScope(auth.ScopeItemsWrite)).Post("/api/items/add", item.Create(log, storage, opts.Enricher))              // добавление подарка в вишлист из ЛК
+		r.With(auth.RequireScope(auth.ScopeListsRead)).Get("/api/wishlist/getforuser", wishlist.GetAllLists(log, storage))            // получение списка вишлистов пользователя в ЛК
+		r.With(auth.RequireScope(auth.ScopeListsWrite)).Post("/api/wishlist/delete", wishlist.Delete(log, storage))                   // удаление конкретного вишлиста в ЛК
+		r.With(auth.RequireScope(auth.ScopeListsWrite)).Patch("/api/wishlist/{wishlistId}", wishlist.Update(log, storage))            // редактирование вишлиста в ЛК
+		r.With(auth.RequireScope(auth.ScopeItemsRead)).Get("/api/wishlist/{wishlistId}/items", item.GetByWishId(log, storage, links)) // получение списка подарков конкретного вишлиста в ЛК
+		r.With(auth.RequireScope(auth.ScopeItemsWrite)).Put("/api/wishlist/{wishlistId}/order", item.Reorder(log, storage))           // ручная сортировка подарков вишлиста в ЛК
+		r.With(auth.RequireScope(auth.ScopeItemsWrite)).Post("/api/items/move", item.Move(log, storage))                              // перенос подарков в другой вишлист в ЛК
+		r.With(auth.RequireScope(auth.ScopeItemsWrite)).Post("/api/items/copy", item.Copy(log, storage))                              // копирование подарков в другой вишлист в ЛК
+		r.With(auth.RequireScope(auth.ScopeItemsWrite)).Post("/api/item/delete", item.Delete(log, storage))                           // удаление подарка из вишлиста в ЛК
+		r.With(auth.RequireScope(auth.ScopeItemsWrite)).Patch("/api/items/{id}", item.Update(log, storage, links))                    // редактирование подарка в ЛК
+		r.With(auth.RequireScope(auth.ScopeItemsRead)).Get("/api/items/{id}/price-history", item.GetPriceHistory(log, storage))       // история цены подарка в ЛК
+	})
+
+	return router
+}
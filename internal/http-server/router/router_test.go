@@ -0,0 +1,1303 @@
+package router_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"wish_list/internal/config"
+	"wish_list/internal/entity"
+	"wish_list/internal/http-server/handlers/auth/uidextractor"
+	"wish_list/internal/http-server/router"
+	"wish_list/internal/lib/affiliate"
+	"wish_list/internal/lib/guest"
+	"wish_list/internal/lib/keyset"
+	"wish_list/internal/lib/session"
+	"wish_list/internal/storage/memory"
+)
+
+type api struct {
+	t     *testing.T
+	srv   *httptest.Server
+	store *memory.Storage
+}
+
+func newAPI(t *testing.T) *api {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := memory.New()
+
+	keys, err := keyset.Load(config.Auth{Keys: []config.Key{{Secret: "test-secret"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validator := uidextractor.New(keys, uidextractor.Options{Denylist: store})
+	issuer := uidextractor.NewIssuer(keys, uidextractor.IssuerOptions{TTL: time.Minute})
+
+	links, err := affiliate.Load(config.Affiliate{Rules: []config.AffiliateRule{
+		{Host: "shop.test", Params: map[string]string{"aff": "wish"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(router.New(log, router.Options{
+		Storage:   store,
+		Validator: validator,
+		Sessions:  session.New(store, issuer, time.Hour),
+		Guests:    guest.New(keys, "", "", time.Hour),
+		Links:     links,
+	}))
+	t.Cleanup(srv.Close)
+
+	return &api{t: t, srv: srv, store: store}
+}
+
+// do sends body as JSON and decodes the response into out when not nil.
+func (a *api) do(method, path, token string, body, out any) int {
+	a.t.Helper()
+
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	return a.send(method, path, header, body, out)
+}
+
+// send is do with arbitrary request headers.
+func (a *api) send(method, path string, header http.Header, body, out any) int {
+	a.t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, a.srv.URL+path, &buf)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := a.srv.Client().Do(req)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			a.t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+type tokens struct {
+	UID          int    `json:"uid"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (a *api) register(login string) tokens {
+	a.t.Helper()
+
+	var out tokens
+	code := a.do(http.MethodPost, "/api/auth/register", "", map[string]string{
+		"login":    login,
+		"password": "correct horse battery",
+	}, &out)
+	if code != http.StatusOK || out.AccessToken == "" {
+		a.t.Fatalf("register %s: status %d", login, code)
+	}
+
+	return out
+}
+
+func (a *api) createList(token, name string) (int, string) {
+	a.t.Helper()
+
+	var out struct {
+		WishListId int    `json:"wish_list_id"`
+		Alias      string `json:"alias"`
+	}
+	if code := a.do(http.MethodPost, "/api/wishlist/create", token, map[string]string{"name": name}, &out); code != http.StatusOK {
+		a.t.Fatalf("create list: status %d", code)
+	}
+
+	return out.WishListId, out.Alias
+}
+
+func TestWishlistFlow(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	bob := a.register("bob")
+
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+
+	var created struct {
+		GiftId int `json:"gift_id"`
+	}
+	code := a.do(http.MethodPost, "/api/items/add", alice.AccessToken, map[string]any{
+		"wish_list_id": listId,
+		"gift_name":    "book",
+		"url":          "https://example.com/book",
+		"price":        map[string]any{"amount": 129900, "currency": "RUB"},
+	}, &created)
+	if code != http.StatusOK || created.GiftId == 0 {
+		t.Fatalf("add item: status %d", code)
+	}
+
+	itemsPath := "/api/wishlist/" + strconv.Itoa(listId) + "/items"
+
+	var owned struct {
+		Items  []map[string]any `json:"items"`
+		Totals map[string]int64 `json:"totals"`
+	}
+	if code := a.do(http.MethodGet, itemsPath, alice.AccessToken, nil, &owned); code != http.StatusOK || len(owned.Items) != 1 {
+		t.Fatalf("owner items: status %d, %d items", code, len(owned.Items))
+	}
+	if owned.Totals["RUB"] != 129900 {
+		t.Fatalf("owner totals = %v, want RUB 129900", owned.Totals)
+	}
+
+	if code := a.do(http.MethodGet, itemsPath, bob.AccessToken, nil, nil); code != http.StatusForbidden {
+		t.Fatalf("foreign items: status %d, want 403", code)
+	}
+	if code := a.do(http.MethodPost, "/api/item/delete", bob.AccessToken, map[string]int{"item_id": created.GiftId}, nil); code != http.StatusForbidden {
+		t.Fatalf("foreign delete: status %d, want 403", code)
+	}
+
+	listPath := "/api/wishlist/" + strconv.Itoa(listId)
+	meta := map[string]string{"description": "turning 30", "event_date": "2024-06-01", "cover_emoji": "🎂"}
+
+	if code := a.do(http.MethodPatch, listPath, bob.AccessToken, meta, nil); code != http.StatusForbidden {
+		t.Fatalf("foreign list update: status %d, want 403", code)
+	}
+	if code := a.do(http.MethodPatch, listPath, alice.AccessToken, map[string]string{"event_date": "June 1"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("invalid event date: status %d, want 400", code)
+	}
+	if code := a.do(http.MethodPatch, listPath, alice.AccessToken, meta, nil); code != http.StatusOK {
+		t.Fatalf("list update: status %d", code)
+	}
+
+	var shared struct {
+		Description string           `json:"description"`
+		EventDate   string           `json:"event_date"`
+		Items       []map[string]any `json:"items"`
+		Totals      map[string]int64 `json:"totals"`
+	}
+	if code := a.do(http.MethodGet, "/api/sharelist/"+alias, "", nil, &shared); code != http.StatusOK || len(shared.Items) != 1 {
+		t.Fatalf("sharelist: status %d, %d items", code, len(shared.Items))
+	}
+	if shared.Description != meta["description"] || shared.EventDate != meta["event_date"] {
+		t.Fatalf("sharelist metadata = %q %q", shared.Description, shared.EventDate)
+	}
+	if shared.Totals["RUB"] != 129900 {
+		t.Fatalf("sharelist totals = %v, want RUB 129900", shared.Totals)
+	}
+
+	if code := a.do(http.MethodGet, itemsPath, "", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous items: status %d, want 401", code)
+	}
+
+	if code := a.do(http.MethodPost, "/api/wishlist/delete", alice.AccessToken, map[string]int{"wish_list_id": listId}, nil); code != http.StatusOK {
+		t.Fatalf("delete list: status %d", code)
+	}
+	if code := a.do(http.MethodGet, "/api/sharelist/"+alias, "", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("deleted sharelist: status %d, want 404", code)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	a := newAPI(t)
+	a.register("carol")
+
+	if code := a.do(http.MethodPost, "/api/auth/register", "", map[string]string{"login": "carol", "password": "another password"}, nil); code != http.StatusConflict {
+		t.Fatalf("duplicate register: status %d, want 409", code)
+	}
+
+	if code := a.do(http.MethodPost, "/api/auth/login", "", map[string]string{"login": "carol", "password": "wrong password"}, nil); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status %d, want 401", code)
+	}
+
+	var out tokens
+	if code := a.do(http.MethodPost, "/api/auth/login", "", map[string]string{"login": "Carol", "password": "correct horse battery"}, &out); code != http.StatusOK {
+		t.Fatalf("login: status %d", code)
+	}
+
+	if code := a.do(http.MethodGet, "/api/wishlist/getforuser", out.AccessToken, nil, nil); code != http.StatusOK {
+		t.Fatalf("issued token rejected: status %d", code)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	a := newAPI(t)
+	first := a.register("dave")
+
+	var second tokens
+	if code := a.do(http.MethodPost, "/api/auth/refresh", "", map[string]string{"refresh_token": first.RefreshToken}, &second); code != http.StatusOK {
+		t.Fatalf("refresh: status %d", code)
+	}
+
+	if code := a.do(http.MethodPost, "/api/auth/refresh", "", map[string]string{"refresh_token": first.RefreshToken}, nil); code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: status %d, want 401", code)
+	}
+
+	if code := a.do(http.MethodPost, "/api/auth/refresh", "", map[string]string{"refresh_token": second.RefreshToken}, nil); code != http.StatusUnauthorized {
+		t.Fatalf("refresh after reuse: status %d, want 401", code)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	a := newAPI(t)
+	s := a.register("erin")
+
+	if code := a.do(http.MethodPost, "/api/auth/logout", s.AccessToken, map[string]string{"refresh_token": s.RefreshToken}, nil); code != http.StatusOK {
+		t.Fatalf("logout: status %d", code)
+	}
+
+	var out struct {
+		Code string `json:"code"`
+	}
+	if code := a.do(http.MethodGet, "/api/wishlist/getforuser", s.AccessToken, nil, &out); code != http.StatusUnauthorized || out.Code != "token_revoked" {
+		t.Fatalf("revoked token: status %d code %q", code, out.Code)
+	}
+
+	if code := a.do(http.MethodPost, "/api/auth/refresh", "", map[string]string{"refresh_token": s.RefreshToken}, nil); code != http.StatusUnauthorized {
+		t.Fatalf("refresh after logout: status %d, want 401", code)
+	}
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	a := newAPI(t)
+	s := a.register("frank")
+
+	var pat struct {
+		TokenId int    `json:"token_id"`
+		Token   string `json:"token"`
+	}
+	code := a.do(http.MethodPost, "/api/tokens", s.AccessToken, map[string]any{
+		"name":   "importer",
+		"scopes": []string{"lists:read"},
+	}, &pat)
+	if code != http.StatusOK || pat.Token == "" {
+		t.Fatalf("create token: status %d", code)
+	}
+
+	if code := a.do(http.MethodGet, "/api/wishlist/getforuser", pat.Token, nil, nil); code != http.StatusOK {
+		t.Fatalf("granted scope: status %d", code)
+	}
+	if code := a.do(http.MethodPost, "/api/wishlist/create", pat.Token, map[string]string{"name": "x"}, nil); code != http.StatusForbidden {
+		t.Fatalf("missing scope: status %d, want 403", code)
+	}
+	if code := a.do(http.MethodPost, "/api/tokens", pat.Token, map[string]any{"name": "y", "scopes": []string{"lists:read"}}, nil); code != http.StatusForbidden {
+		t.Fatalf("token minting with api token: status %d, want 403", code)
+	}
+
+	_, alias := a.createList(s.AccessToken, "birthday")
+	if code := a.do(http.MethodGet, "/api/sharelist/"+alias, pat.Token, nil, nil); code != http.StatusForbidden {
+		t.Fatalf("shared list without share:read: status %d, want 403", code)
+	}
+	var reader struct {
+		Token string `json:"token"`
+	}
+	a.do(http.MethodPost, "/api/tokens", s.AccessToken, map[string]any{"name": "viewer", "scopes": []string{"share:read"}}, &reader)
+	if code := a.do(http.MethodGet, "/api/sharelist/"+alias, reader.Token, nil, nil); code != http.StatusOK {
+		t.Fatalf("shared list with share:read: status %d", code)
+	}
+
+	// Someone else's token and a missing one look the same.
+	other := a.register("mallory")
+	if code := a.do(http.MethodDelete, "/api/tokens/"+strconv.Itoa(pat.TokenId), other.AccessToken, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("revoke foreign token: status %d, want 404", code)
+	}
+	if code := a.do(http.MethodDelete, "/api/tokens/999", s.AccessToken, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("revoke missing token: status %d, want 404", code)
+	}
+
+	if code := a.do(http.MethodDelete, "/api/tokens/"+strconv.Itoa(pat.TokenId), s.AccessToken, nil, nil); code != http.StatusOK {
+		t.Fatalf("revoke token: status %d", code)
+	}
+	if code := a.do(http.MethodGet, "/api/wishlist/getforuser", pat.Token, nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("revoked api token: status %d, want 401", code)
+	}
+}
+
+func (a *api) addItem(token string, listId int, name string) int {
+	a.t.Helper()
+
+	var out struct {
+		GiftId int `json:"gift_id"`
+	}
+	code := a.do(http.MethodPost, "/api/items/add", token, map[string]any{
+		"wish_list_id": listId,
+		"gift_name":    name,
+		"url":          "https://example.com/" + name,
+	}, &out)
+	if code != http.StatusOK {
+		a.t.Fatalf("add item: status %d", code)
+	}
+
+	return out.GiftId
+}
+
+func (a *api) guestToken() string {
+	a.t.Helper()
+
+	var out struct {
+		GuestToken string `json:"guest_token"`
+	}
+	if code := a.do(http.MethodPost, "/api/guest/token", "", nil, &out); code != http.StatusOK || out.GuestToken == "" {
+		a.t.Fatalf("guest token: status %d", code)
+	}
+
+	return out.GuestToken
+}
+
+func asGuest(token string) http.Header {
+	return http.Header{"X-Guest-Token": {token}}
+}
+
+type sharedItem struct {
+	GiftId       int  `json:"gift_id"`
+	Quantity     int  `json:"quantity"`
+	Remaining    *int `json:"remaining"`
+	Reservations []struct {
+		Quantity int    `json:"quantity"`
+		Name     string `json:"name"`
+		Mine     bool   `json:"mine"`
+	} `json:"reservations"`
+}
+
+func TestReservations(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	bob := a.register("bob")
+
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+	giftId := a.addItem(alice.AccessToken, listId, "book")
+
+	reservePath := "/api/sharelist/" + alias + "/items/" + strconv.Itoa(giftId) + "/reserve"
+	itemsPath := "/api/wishlist/" + strconv.Itoa(listId) + "/items"
+
+	mary, john := a.guestToken(), a.guestToken()
+
+	if code := a.do(http.MethodPost, reservePath, "", nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("anonymous reserve: status %d, want 401", code)
+	}
+	if code := a.send(http.MethodPost, reservePath, asGuest("forged"), nil, nil); code != http.StatusUnauthorized {
+		t.Fatalf("forged guest token: status %d, want 401", code)
+	}
+	if code := a.do(http.MethodPost, reservePath, alice.AccessToken, nil, nil); code != http.StatusForbidden {
+		t.Fatalf("owner reserve: status %d, want 403", code)
+	}
+	if code := a.send(http.MethodPost, reservePath, asGuest(mary), map[string]string{"name": "Aunt Mary"}, nil); code != http.StatusOK {
+		t.Fatalf("guest reserve: status %d", code)
+	}
+	if code := a.send(http.MethodPost, reservePath, asGuest(john), nil, nil); code != http.StatusConflict {
+		t.Fatalf("second reserve: status %d, want 409", code)
+	}
+	if code := a.send(http.MethodPost, reservePath, asGuest(mary), nil, nil); code != http.StatusConflict {
+		t.Fatalf("reserving more than the quantity: status %d, want 409", code)
+	}
+	if code := a.do(http.MethodPost, reservePath, bob.AccessToken, nil, nil); code != http.StatusConflict {
+		t.Fatalf("account reserve of taken item: status %d, want 409", code)
+	}
+
+	var shared struct {
+		Items []sharedItem `json:"items"`
+	}
+	a.send(http.MethodGet, "/api/sharelist/"+alias, asGuest(mary), nil, &shared)
+	if it := shared.Items[0]; len(it.Reservations) != 1 || !it.Reservations[0].Mine || *it.Remaining != 0 {
+		t.Fatalf("reserver view = %+v, want own reservation", it)
+	}
+	shared.Items = nil
+	a.send(http.MethodGet, "/api/sharelist/"+alias, asGuest(john), nil, &shared)
+	if it := shared.Items[0]; len(it.Reservations) != 0 || it.Remaining == nil || *it.Remaining != 0 {
+		t.Fatalf("other guest view = %+v, want nothing left and no reservations", it)
+	}
+
+	var owned struct {
+		Items []sharedItem `json:"items"`
+	}
+	a.do(http.MethodGet, itemsPath, alice.AccessToken, nil, &owned)
+	if it := owned.Items[0]; it.Reservations != nil || it.Remaining != nil {
+		t.Fatal("surprise mode leaked a reservation to the owner")
+	}
+	shared.Items = nil
+	a.do(http.MethodGet, "/api/sharelist/"+alias, alice.AccessToken, nil, &shared)
+	if it := shared.Items[0]; it.Reservations != nil || it.Remaining != nil {
+		t.Fatal("surprise mode leaked a reservation to the owner through the share link")
+	}
+
+	if code := a.do(http.MethodPatch, "/api/wishlist/"+strconv.Itoa(listId), alice.AccessToken, map[string]bool{"surprise": false}, nil); code != http.StatusOK {
+		t.Fatalf("disable surprise: status %d", code)
+	}
+	owned.Items = nil
+	a.do(http.MethodGet, itemsPath, alice.AccessToken, nil, &owned)
+	if res := owned.Items[0].Reservations; len(res) != 1 || res[0].Name != "Aunt Mary" {
+		t.Fatalf("owner view without surprise = %+v", res)
+	}
+
+	if code := a.send(http.MethodDelete, reservePath, asGuest(john), nil, nil); code != http.StatusNotFound {
+		t.Fatalf("foreign unreserve: status %d, want 404", code)
+	}
+	if code := a.send(http.MethodDelete, reservePath, asGuest(mary), nil, nil); code != http.StatusOK {
+		t.Fatalf("unreserve: status %d", code)
+	}
+	if code := a.send(http.MethodDelete, reservePath, asGuest(mary), nil, nil); code != http.StatusNotFound {
+		t.Fatalf("repeated unreserve: status %d, want 404", code)
+	}
+	if code := a.do(http.MethodPost, reservePath, bob.AccessToken, nil, nil); code != http.StatusOK {
+		t.Fatalf("account reserve: status %d", code)
+	}
+}
+
+func TestSurpriseHidesClaimConflicts(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+	bookId := a.addItem(alice.AccessToken, listId, "book")
+
+	var bike struct {
+		GiftId int `json:"gift_id"`
+	}
+	a.do(http.MethodPost, "/api/items/add", alice.AccessToken, map[string]any{
+		"wish_list_id": listId,
+		"gift_name":    "bike",
+		"url":          "https://example.com/bike",
+		"price":        map[string]any{"amount": 10000, "currency": "RUB"},
+	}, &bike)
+
+	bookPath := "/api/items/" + strconv.Itoa(bookId)
+	bikePath := "/api/items/" + strconv.Itoa(bike.GiftId)
+	shared := "/api/sharelist/" + alias + "/items/"
+	mary := a.guestToken()
+
+	if code := a.do(http.MethodPatch, bookPath, alice.AccessToken, map[string]int{"quantity": 2}, nil); code != http.StatusOK {
+		t.Fatalf("set quantity: status %d", code)
+	}
+	if code := a.send(http.MethodPost, shared+strconv.Itoa(bookId)+"/reserve", asGuest(mary), map[string]int{"quantity": 2}, nil); code != http.StatusOK {
+		t.Fatalf("reserve: status %d", code)
+	}
+	if code := a.send(http.MethodPost, shared+strconv.Itoa(bike.GiftId)+"/contributions", asGuest(mary), map[string]int{"amount": 100}, nil); code != http.StatusOK {
+		t.Fatalf("pledge: status %d", code)
+	}
+
+	patch := func(path string, body any) (int, string) {
+		var out struct {
+			Error string `json:"error"`
+		}
+		code := a.do(http.MethodPatch, path, alice.AccessToken, body, &out)
+		return code, out.Error
+	}
+
+	const hidden = "this change is not possible while the wishlist is in surprise mode"
+
+	if code, msg := patch(bookPath, map[string]int{"quantity": 1}); code != http.StatusConflict || msg != hidden {
+		t.Fatalf("quantity below a hidden reservation: status %d, error %q", code, msg)
+	}
+	if code, msg := patch(bikePath, map[string]any{"price": nil}); code != http.StatusConflict || msg != hidden {
+		t.Fatalf("price of an item with hidden pledges: status %d, error %q", code, msg)
+	}
+
+	if code := a.do(http.MethodPatch, "/api/wishlist/"+strconv.Itoa(listId), alice.AccessToken, map[string]bool{"surprise": false}, nil); code != http.StatusOK {
+		t.Fatalf("turn off surprise mode: status %d", code)
+	}
+
+	if code, msg := patch(bookPath, map[string]int{"quantity": 1}); code != http.StatusConflict || msg == hidden {
+		t.Fatalf("quantity below a visible reservation: status %d, error %q", code, msg)
+	}
+	if code, msg := patch(bikePath, map[string]any{"price": nil}); code != http.StatusConflict || msg == hidden {
+		t.Fatalf("price of an item with visible pledges: status %d, error %q", code, msg)
+	}
+}
+
+func TestConcurrentReservations(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	listId, alias := a.createList(alice.AccessToken, "wedding")
+
+	var created struct {
+		GiftId int `json:"gift_id"`
+	}
+	a.do(http.MethodPost, "/api/items/add", alice.AccessToken, map[string]any{
+		"wish_list_id": listId,
+		"gift_name":    "wine glass",
+		"url":          "https://example.com/glass",
+		"quantity":     5,
+	}, &created)
+
+	reservePath := "/api/sharelist/" + alias + "/items/" + strconv.Itoa(created.GiftId) + "/reserve"
+
+	const guests = 20
+
+	tokens := make([]string, guests)
+	for i := range tokens {
+		tokens[i] = a.guestToken()
+	}
+
+	codes := make(chan int, guests)
+	var wg sync.WaitGroup
+	for _, token := range tokens {
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			codes <- a.send(http.MethodPost, reservePath, asGuest(token), map[string]int{"quantity": 2}, nil)
+		}(token)
+	}
+	wg.Wait()
+	close(codes)
+
+	var reserved int
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			reserved++
+		case http.StatusConflict:
+		default:
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	if reserved != 2 {
+		t.Fatalf("%d successful reservations of 2 units out of 5, want 2", reserved)
+	}
+
+	var shared struct {
+		Items []sharedItem `json:"items"`
+	}
+	a.send(http.MethodGet, "/api/sharelist/"+alias, asGuest(tokens[0]), nil, &shared)
+	if it := shared.Items[0]; it.Quantity != 5 || it.Remaining == nil || *it.Remaining != 1 {
+		t.Fatalf("shared item = %+v, want 1 of 5 left", it)
+	}
+
+	if code := a.do(http.MethodPatch, "/api/items/"+strconv.Itoa(created.GiftId), alice.AccessToken, map[string]int{"quantity": 3}, nil); code != http.StatusConflict {
+		t.Fatalf("quantity below reserved units: status %d, want 409", code)
+	}
+}
+
+func TestGroupGift(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+
+	var created struct {
+		GiftId int `json:"gift_id"`
+	}
+	a.do(http.MethodPost, "/api/items/add", alice.AccessToken, map[string]any{
+		"wish_list_id": listId,
+		"gift_name":    "bike",
+		"url":          "https://example.com/bike",
+		"price":        map[string]any{"amount": 10000, "currency": "RUB"},
+	}, &created)
+	unpriced := a.addItem(alice.AccessToken, listId, "card")
+
+	itemPath := "/api/sharelist/" + alias + "/items/" + strconv.Itoa(created.GiftId)
+	mary, john := a.guestToken(), a.guestToken()
+
+	pledge := func(guest string, amount int) (int, int) {
+		var out struct {
+			ContributionId int `json:"contribution_id"`
+		}
+		code := a.send(http.MethodPost, itemPath+"/contributions", asGuest(guest), map[string]int{"amount": amount}, &out)
+		return code, out.ContributionId
+	}
+
+	type funding struct {
+		Pledged       int64 `json:"pledged"`
+		Target        int64 `json:"target"`
+		FullyFunded   bool  `json:"fully_funded"`
+		Contributions []struct {
+			ContributionId int  `json:"contribution_id"`
+			Mine           bool `json:"mine"`
+		} `json:"contributions"`
+	}
+	progress := func(guest string) *funding {
+		var out struct {
+			Items []struct {
+				GiftId  int      `json:"gift_id"`
+				Funding *funding `json:"funding"`
+			} `json:"items"`
+		}
+		a.send(http.MethodGet, "/api/sharelist/"+alias, asGuest(guest), nil, &out)
+		for _, it := range out.Items {
+			if it.GiftId == created.GiftId {
+				return it.Funding
+			}
+		}
+		return nil
+	}
+
+	code := a.send(http.MethodPost, "/api/sharelist/"+alias+"/items/"+strconv.Itoa(unpriced)+"/contributions", asGuest(mary), map[string]int{"amount": 100}, nil)
+	if code != http.StatusConflict {
+		t.Fatalf("pledge for unpriced item: status %d, want 409", code)
+	}
+	if code, _ := pledge(mary, 0); code != http.StatusBadRequest {
+		t.Fatalf("zero pledge: status %d, want 400", code)
+	}
+
+	code, maryId := pledge(mary, 6000)
+	if code != http.StatusOK {
+		t.Fatalf("pledge: status %d", code)
+	}
+	if code, _ := pledge(john, 5000); code != http.StatusConflict {
+		t.Fatalf("overfunding pledge: status %d, want 409", code)
+	}
+	if code := a.send(http.MethodPost, itemPath+"/reserve", asGuest(john), nil, nil); code != http.StatusConflict {
+		t.Fatalf("reserve of group gift: status %d, want 409", code)
+	}
+
+	code, johnId := pledge(john, 4000)
+	if code != http.StatusOK {
+		t.Fatalf("pledge: status %d", code)
+	}
+
+	f := progress(john)
+	if f == nil || f.Pledged != 10000 || f.Target != 10000 || !f.FullyFunded {
+		t.Fatalf("progress = %+v, want fully funded", f)
+	}
+	if len(f.Contributions) != 1 || f.Contributions[0].ContributionId != johnId || !f.Contributions[0].Mine {
+		t.Fatalf("guest sees contributions %+v, want only their own", f.Contributions)
+	}
+
+	var owned struct {
+		Items []struct {
+			Funding *funding `json:"funding"`
+		} `json:"items"`
+	}
+	a.do(http.MethodGet, "/api/wishlist/"+strconv.Itoa(listId)+"/items", alice.AccessToken, nil, &owned)
+	for _, it := range owned.Items {
+		if it.Funding != nil {
+			t.Fatal("surprise mode leaked group gift progress to the owner")
+		}
+	}
+
+	withdrawPath := itemPath + "/contributions/" + strconv.Itoa(maryId)
+	if code := a.send(http.MethodDelete, withdrawPath, asGuest(john), nil, nil); code != http.StatusForbidden {
+		t.Fatalf("foreign withdraw: status %d, want 403", code)
+	}
+	if code := a.send(http.MethodDelete, withdrawPath, asGuest(mary), nil, nil); code != http.StatusOK {
+		t.Fatalf("withdraw: status %d", code)
+	}
+
+	if f := progress(mary); f == nil || f.Pledged != 4000 || f.FullyFunded || len(f.Contributions) != 0 {
+		t.Fatalf("progress after withdraw = %+v", f)
+	}
+
+	patchPath := "/api/items/" + strconv.Itoa(created.GiftId)
+	for _, price := range []any{
+		map[string]any{"amount": 3000, "currency": "RUB"},
+		map[string]any{"amount": 10000, "currency": "USD"},
+		nil,
+	} {
+		if code := a.do(http.MethodPatch, patchPath, alice.AccessToken, map[string]any{"price": price}, nil); code != http.StatusConflict {
+			t.Fatalf("price %v of a pledged item: status %d, want 409", price, code)
+		}
+	}
+	if code := a.do(http.MethodPatch, patchPath, alice.AccessToken, map[string]any{"price": map[string]any{"amount": 10000, "currency": "rub"}}, nil); code != http.StatusOK {
+		t.Fatalf("same price of a pledged item: status %d", code)
+	}
+	if f := progress(john); f == nil || f.Pledged != 4000 || f.Target != 10000 {
+		t.Fatalf("progress after price changes = %+v", f)
+	}
+
+	if code := a.send(http.MethodDelete, itemPath+"/contributions/"+strconv.Itoa(johnId), asGuest(john), nil, nil); code != http.StatusOK {
+		t.Fatalf("withdraw: status %d", code)
+	}
+	if code := a.do(http.MethodPatch, patchPath, alice.AccessToken, map[string]any{"price": nil}, nil); code != http.StatusOK {
+		t.Fatalf("clear price without pledges: status %d", code)
+	}
+}
+
+func TestPledgeOverflow(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+
+	var created struct {
+		GiftId int `json:"gift_id"`
+	}
+	a.do(http.MethodPost, "/api/items/add", alice.AccessToken, map[string]any{
+		"wish_list_id": listId,
+		"gift_name":    "bike",
+		"url":          "https://example.com/bike",
+		"price":        map[string]any{"amount": 10000, "currency": "RUB"},
+	}, &created)
+
+	path := "/api/sharelist/" + alias + "/items/" + strconv.Itoa(created.GiftId) + "/contributions"
+	mary, john := a.guestToken(), a.guestToken()
+
+	if code := a.send(http.MethodPost, path, asGuest(mary), map[string]int64{"amount": 1}, nil); code != http.StatusOK {
+		t.Fatalf("pledge: status %d", code)
+	}
+	// 1 + MaxInt64 wraps around to a negative sum.
+	if code := a.send(http.MethodPost, path, asGuest(john), map[string]int64{"amount": math.MaxInt64}, nil); code != http.StatusConflict {
+		t.Fatalf("huge pledge: status %d, want 409", code)
+	}
+
+	var out struct {
+		Items []struct {
+			Funding *struct {
+				Pledged int64 `json:"pledged"`
+			} `json:"funding"`
+		} `json:"items"`
+	}
+	if code := a.send(http.MethodGet, "/api/sharelist/"+alias, asGuest(mary), nil, &out); code != http.StatusOK {
+		t.Fatalf("shared list: status %d", code)
+	}
+	if len(out.Items) != 1 || out.Items[0].Funding == nil || out.Items[0].Funding.Pledged != 1 {
+		t.Fatalf("items = %+v, want 1 pledged", out.Items)
+	}
+}
+
+func TestItemOrder(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	bob := a.register("bob")
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+
+	first := a.addItem(alice.AccessToken, listId, "book")
+	second := a.addItem(alice.AccessToken, listId, "lamp")
+	third := a.addItem(alice.AccessToken, listId, "scarf")
+
+	itemPath := "/api/items/" + strconv.Itoa(third)
+	if code := a.do(http.MethodPatch, itemPath, alice.AccessToken, map[string]string{"priority": "must_have"}, nil); code != http.StatusOK {
+		t.Fatalf("set priority: status %d", code)
+	}
+	if code := a.do(http.MethodPatch, itemPath, alice.AccessToken, map[string]string{"priority": "urgent"}, nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown priority: status %d, want 400", code)
+	}
+
+	type ordered struct {
+		Items []struct {
+			GiftId   int    `json:"gift_id"`
+			Priority string `json:"priority"`
+		} `json:"items"`
+	}
+	ids := func(out ordered) []int {
+		var ids []int
+		for _, it := range out.Items {
+			ids = append(ids, it.GiftId)
+		}
+		return ids
+	}
+
+	orderPath := "/api/wishlist/" + strconv.Itoa(listId) + "/order"
+	for _, bad := range [][]int{{third, first}, {third, first, first}, {third, first, second, 999}} {
+		if code := a.do(http.MethodPut, orderPath, alice.AccessToken, map[string][]int{"gift_ids": bad}, nil); code != http.StatusConflict {
+			t.Fatalf("reorder %v: status %d, want 409", bad, code)
+		}
+	}
+	want := []int{third, first, second}
+	if code := a.do(http.MethodPut, orderPath, bob.AccessToken, map[string][]int{"gift_ids": want}, nil); code != http.StatusForbidden {
+		t.Fatalf("foreign reorder: status %d, want 403", code)
+	}
+	if code := a.do(http.MethodPut, orderPath, alice.AccessToken, map[string][]int{"gift_ids": want}, nil); code != http.StatusOK {
+		t.Fatalf("reorder: status %d", code)
+	}
+
+	var owned ordered
+	a.do(http.MethodGet, "/api/wishlist/"+strconv.Itoa(listId)+"/items", alice.AccessToken, nil, &owned)
+	if got := ids(owned); !slices.Equal(got, want) {
+		t.Fatalf("owner order = %v, want %v", got, want)
+	}
+	if owned.Items[0].Priority != "must_have" || owned.Items[1].Priority != "nice_to_have" {
+		t.Fatalf("priorities = %+v", owned.Items)
+	}
+
+	var shared ordered
+	a.do(http.MethodGet, "/api/sharelist/"+alias, "", nil, &shared)
+	if got := ids(shared); !slices.Equal(got, want) {
+		t.Fatalf("shared order = %v, want %v", got, want)
+	}
+
+	fourth := a.addItem(alice.AccessToken, listId, "mug")
+	owned.Items = nil
+	a.do(http.MethodGet, "/api/wishlist/"+strconv.Itoa(listId)+"/items", alice.AccessToken, nil, &owned)
+	if got := ids(owned); len(got) != 4 || got[3] != fourth {
+		t.Fatalf("new item order = %v, want it appended", got)
+	}
+}
+
+func TestItemDetails(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+
+	var created struct {
+		GiftId int `json:"gift_id"`
+	}
+	a.do(http.MethodPost, "/api/items/add", alice.AccessToken, map[string]any{
+		"wish_list_id": listId,
+		"gift_name":    "sweater",
+		"url":          "https://example.com/sweater",
+		"description":  " wool, not itchy ",
+		"image_url":    "https://example.com/sweater.jpg",
+		"options":      map[string]string{"size": "M", "color": "navy"},
+	}, &created)
+
+	type detailed struct {
+		Items []struct {
+			Description string            `json:"description"`
+			ImageUrl    string            `json:"image_url"`
+			Options     map[string]string `json:"options"`
+		} `json:"items"`
+	}
+
+	var owned, shared detailed
+	a.do(http.MethodGet, "/api/wishlist/"+strconv.Itoa(listId)+"/items", alice.AccessToken, nil, &owned)
+	a.do(http.MethodGet, "/api/sharelist/"+alias, "", nil, &shared)
+
+	for view, out := range map[string]detailed{"owner": owned, "shared": shared} {
+		if len(out.Items) != 1 {
+			t.Fatalf("%s view: %d items", view, len(out.Items))
+		}
+		it := out.Items[0]
+		if it.Description != "wool, not itchy" || it.ImageUrl != "https://example.com/sweater.jpg" ||
+			it.Options["size"] != "M" || it.Options["color"] != "navy" {
+			t.Fatalf("%s view: details = %+v", view, it)
+		}
+	}
+
+	var updated struct {
+		Options map[string]string `json:"options"`
+	}
+	code := a.do(http.MethodPatch, "/api/items/"+strconv.Itoa(created.GiftId), alice.AccessToken, map[string]any{"options": map[string]string{}}, &updated)
+	if code != http.StatusOK || updated.Options == nil || len(updated.Options) != 0 {
+		t.Fatalf("clear options: status %d, options %v", code, updated.Options)
+	}
+}
+
+func TestPriceHistory(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	bob := a.register("bob")
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+	itemId := a.addItem(alice.AccessToken, listId, "kettle")
+	path := "/api/items/" + strconv.Itoa(itemId)
+
+	var history struct {
+		GiftId  int `json:"gift_id"`
+		History []struct {
+			Amount    int64     `json:"amount"`
+			Currency  string    `json:"currency"`
+			CheckedAt time.Time `json:"checked_at"`
+		} `json:"history"`
+	}
+	code := a.do(http.MethodGet, path+"/price-history", alice.AccessToken, nil, &history)
+	if code != http.StatusOK || history.GiftId != itemId || history.History == nil || len(history.History) != 0 {
+		t.Fatalf("empty history: status %d, %+v", code, history)
+	}
+
+	checked := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, amount := range []int64{250000, 199900} {
+		price := &entity.Price{Amount: amount, Currency: "RUB"}
+		if err := a.store.RecordPrice(context.Background(), itemId, price, checked.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code = a.do(http.MethodGet, path+"/price-history", alice.AccessToken, nil, &history)
+	if code != http.StatusOK || len(history.History) != 2 ||
+		history.History[0].Amount != 250000 || history.History[1].Amount != 199900 ||
+		!history.History[1].CheckedAt.Equal(checked.Add(time.Hour)) {
+		t.Fatalf("history: status %d, %+v", code, history)
+	}
+
+	if code := a.do(http.MethodGet, path+"/price-history", bob.AccessToken, nil, nil); code != http.StatusForbidden {
+		t.Fatalf("foreign history: status %d, want 403", code)
+	}
+	if code := a.do(http.MethodGet, "/api/items/999/price-history", alice.AccessToken, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("missing item: status %d, want 404", code)
+	}
+
+	type alerted struct {
+		PriceAlert *entity.Price `json:"price_alert"`
+	}
+	var updated alerted
+	code = a.do(http.MethodPatch, path, alice.AccessToken, map[string]any{
+		"price_alert": map[string]any{"amount": 180000, "currency": "RUB"},
+	}, &updated)
+	if code != http.StatusOK || updated.PriceAlert == nil || updated.PriceAlert.Amount != 180000 {
+		t.Fatalf("set alert: status %d, %+v", code, updated)
+	}
+
+	var shared struct {
+		Items []alerted `json:"items"`
+	}
+	a.do(http.MethodGet, "/api/sharelist/"+alias, "", nil, &shared)
+	if len(shared.Items) != 1 || shared.Items[0].PriceAlert != nil {
+		t.Fatalf("shared view exposes the price alert: %+v", shared.Items)
+	}
+
+	updated = alerted{}
+	code = a.do(http.MethodPatch, path, alice.AccessToken, map[string]any{"price_alert": nil}, &updated)
+	if code != http.StatusOK || updated.PriceAlert != nil {
+		t.Fatalf("clear alert: status %d, %+v", code, updated)
+	}
+}
+
+func TestLinkHealth(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+	itemId := a.addItem(alice.AccessToken, listId, "kettle")
+
+	var item struct {
+		Url string `json:"url"`
+	}
+	a.do(http.MethodPatch, "/api/items/"+strconv.Itoa(itemId), alice.AccessToken, map[string]any{"url": "https://example.com/kettle"}, &item)
+
+	checked := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := a.store.RecordLink(context.Background(), itemId, item.Url, entity.LinkNotFound, checked); err != nil {
+		t.Fatal(err)
+	}
+
+	type linked struct {
+		Items []struct {
+			Link *entity.LinkHealth `json:"link"`
+		} `json:"items"`
+	}
+
+	var owned, shared linked
+	a.do(http.MethodGet, "/api/wishlist/"+strconv.Itoa(listId)+"/items", alice.AccessToken, nil, &owned)
+	if len(owned.Items) != 1 || owned.Items[0].Link == nil || !owned.Items[0].Link.Broken ||
+		owned.Items[0].Link.Status != entity.LinkNotFound || !owned.Items[0].Link.CheckedAt.Equal(checked) {
+		t.Fatalf("owner view: %+v", owned.Items)
+	}
+
+	a.do(http.MethodGet, "/api/sharelist/"+alias, "", nil, &shared)
+	if len(shared.Items) != 1 || shared.Items[0].Link != nil {
+		t.Fatalf("shared view exposes link health: %+v", shared.Items)
+	}
+
+	a.do(http.MethodPatch, "/api/items/"+strconv.Itoa(itemId), alice.AccessToken, map[string]any{"url": "https://example.com/kettle-2"}, nil)
+	owned = linked{}
+	a.do(http.MethodGet, "/api/wishlist/"+strconv.Itoa(listId)+"/items", alice.AccessToken, nil, &owned)
+	if len(owned.Items) != 1 || owned.Items[0].Link != nil {
+		t.Fatalf("link health kept after the url changed: %+v", owned.Items)
+	}
+}
+
+func TestDuplicateUrl(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	listId, _ := a.createList(alice.AccessToken, "birthday")
+	otherId, _ := a.createList(alice.AccessToken, "new year")
+	a.addItem(alice.AccessToken, listId, "kettle")
+	lampId := a.addItem(alice.AccessToken, listId, "lamp")
+
+	add := func(listId int, url string) int {
+		return a.do(http.MethodPost, "/api/items/add", alice.AccessToken, map[string]any{
+			"wish_list_id": listId,
+			"gift_name":    "kettle",
+			"url":          url,
+		}, nil)
+	}
+
+	if code := add(listId, "https://Example.com/kettle?utm_source=newsletter"); code != http.StatusConflict {
+		t.Fatalf("duplicate in the same list: status %d, want 409", code)
+	}
+	if code := add(otherId, "https://example.com/kettle"); code != http.StatusOK {
+		t.Fatalf("same url in another list: status %d", code)
+	}
+
+	path := "/api/items/" + strconv.Itoa(lampId)
+	if code := a.do(http.MethodPatch, path, alice.AccessToken, map[string]any{"url": "https://example.com/kettle#top"}, nil); code != http.StatusOK {
+		t.Fatalf("distinct fragment: status %d", code)
+	}
+	if code := a.do(http.MethodPatch, path, alice.AccessToken, map[string]any{"url": "https://example.com/kettle"}, nil); code != http.StatusConflict {
+		t.Fatalf("update to a duplicate: status %d, want 409", code)
+	}
+}
+
+func TestAffiliateLinks(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	listId, alias := a.createList(alice.AccessToken, "birthday")
+
+	var created struct {
+		GiftId int `json:"gift_id"`
+	}
+	a.do(http.MethodPost, "/api/items/add", alice.AccessToken, map[string]any{
+		"wish_list_id": listId,
+		"gift_name":    "kettle",
+		"url":          "https://www.shop.test/kettle",
+	}, &created)
+	a.addItem(alice.AccessToken, listId, "lamp")
+
+	const (
+		stored    = "https://www.shop.test/kettle"
+		rewritten = "https://www.shop.test/kettle?aff=wish"
+	)
+
+	type linked struct {
+		Url       string `json:"url"`
+		SourceUrl string `json:"source_url"`
+		Clicks    int    `json:"clicks"`
+	}
+	var owned, shared struct {
+		Items []linked `json:"items"`
+	}
+
+	a.do(http.MethodGet, "/api/wishlist/"+strconv.Itoa(listId)+"/items", alice.AccessToken, nil, &owned)
+	if len(owned.Items) != 2 || owned.Items[0].Url != rewritten || owned.Items[0].SourceUrl != stored ||
+		owned.Items[1].Url != "https://example.com/lamp" || owned.Items[1].SourceUrl != "" {
+		t.Fatalf("owner view: %+v", owned.Items)
+	}
+
+	a.do(http.MethodGet, "/api/sharelist/"+alias, "", nil, &shared)
+	if len(shared.Items) != 2 || shared.Items[0].Url != rewritten || shared.Items[0].SourceUrl != "" {
+		t.Fatalf("shared view: %+v", shared.Items)
+	}
+
+	var updated linked
+	path := "/api/items/" + strconv.Itoa(created.GiftId)
+	a.do(http.MethodPatch, path, alice.AccessToken, map[string]any{"gift_name": "electric kettle"}, &updated)
+	if updated.Url != rewritten || updated.SourceUrl != stored {
+		t.Fatalf("updated item: %+v", updated)
+	}
+
+	client := *a.srv.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	visit := func(id string) *http.Response {
+		t.Helper()
+		res, err := client.Get(a.srv.URL + "/go/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	for i := 0; i < 2; i++ {
+		res := visit(strconv.Itoa(created.GiftId))
+		if res.StatusCode != http.StatusFound || res.Header.Get("Location") != rewritten {
+			t.Fatalf("redirect: status %d, location %q", res.StatusCode, res.Header.Get("Location"))
+		}
+		if res.Header.Get("Cache-Control") != "no-store" {
+			t.Fatalf("redirect may be cached: %q", res.Header.Get("Cache-Control"))
+		}
+	}
+	if res := visit("999"); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing item: status %d, want 404", res.StatusCode)
+	}
+
+	hiddenId, err := a.store.CreateList(context.Background(), "drafts", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hiddenItem, err := a.store.CreateItem(context.Background(), hiddenId, 1, entity.GiftCreate{Name: "watch", Url: "https://example.com/watch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := visit(strconv.Itoa(hiddenItem)); res.StatusCode != http.StatusNotFound {
+		t.Fatalf("item of an unpublished list: status %d, want 404", res.StatusCode)
+	}
+	if res := visit("kettle"); res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid id: status %d, want 400", res.StatusCode)
+	}
+
+	owned.Items = nil
+	a.do(http.MethodGet, "/api/wishlist/"+strconv.Itoa(listId)+"/items", alice.AccessToken, nil, &owned)
+	if owned.Items[0].Clicks != 2 || owned.Items[1].Clicks != 0 {
+		t.Fatalf("clicks: %+v", owned.Items)
+	}
+
+	shared.Items = nil
+	a.do(http.MethodGet, "/api/sharelist/"+alias, "", nil, &shared)
+	if shared.Items[0].Clicks != 0 {
+		t.Fatalf("shared view exposes clicks: %+v", shared.Items)
+	}
+}
+
+func TestMoveCopyItems(t *testing.T) {
+	a := newAPI(t)
+
+	alice := a.register("alice")
+	bob := a.register("bob")
+
+	birthdayId, birthday := a.createList(alice.AccessToken, "birthday")
+	newYearId, newYear := a.createList(alice.AccessToken, "new year")
+	bobListId, _ := a.createList(bob.AccessToken, "wedding")
+
+	book := a.addItem(alice.AccessToken, birthdayId, "book")
+	lamp := a.addItem(alice.AccessToken, birthdayId, "lamp")
+	mug := a.addItem(alice.AccessToken, newYearId, "mug")
+	pen := a.addItem(bob.AccessToken, bobListId, "pen")
+
+	mary := a.guestToken()
+	reservePath := "/api/sharelist/" + birthday + "/items/" + strconv.Itoa(book) + "/reserve"
+	if code := a.send(http.MethodPost, reservePath, asGuest(mary), nil, nil); code != http.StatusOK {
+		t.Fatalf("reserve: status %d", code)
+	}
+
+	shared := func(alias string) []sharedItem {
+		var out struct {
+			Items []sharedItem `json:"items"`
+		}
+		a.send(http.MethodGet, "/api/sharelist/"+alias, asGuest(mary), nil, &out)
+		return out.Items
+	}
+	transfer := func(action, token string, listId int, giftIds ...int) int {
+		return a.do(http.MethodPost, "/api/items/"+action, token, map[string]any{
+			"wish_list_id": listId,
+			"gift_ids":     giftIds,
+		}, nil)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		token  string
+		listId int
+		ids    []int
+		want   int
+	}{
+		{"no items", alice.AccessToken, newYearId, nil, http.StatusBadRequest},
+		{"repeated item", alice.AccessToken, newYearId, []int{lamp, lamp}, http.StatusBadRequest},
+		{"missing list", alice.AccessToken, 999, []int{lamp}, http.StatusNotFound},
+		{"missing item", alice.AccessToken, newYearId, []int{lamp, 999}, http.StatusNotFound},
+		{"foreign list", alice.AccessToken, bobListId, []int{lamp}, http.StatusForbidden},
+		{"foreign item", alice.AccessToken, newYearId, []int{lamp, pen}, http.StatusForbidden},
+		{"foreign items into own list", bob.AccessToken, bobListId, []int{lamp}, http.StatusForbidden},
+	} {
+		for _, action := range []string{"move", "copy"} {
+			if code := transfer(action, tc.token, tc.listId, tc.ids...); code != tc.want {
+				t.Fatalf("%s %s: status %d, want %d", action, tc.name, code, tc.want)
+			}
+		}
+	}
+	if items := shared(newYear); len(items) != 1 {
+		t.Fatalf("failed transfers changed the target: %+v", items)
+	}
+
+	if code := transfer("move", alice.AccessToken, newYearId, book); code != http.StatusOK {
+		t.Fatalf("move: status %d", code)
+	}
+	items := shared(newYear)
+	if len(items) != 2 || items[0].GiftId != mug || items[1].GiftId != book {
+		t.Fatalf("target after move = %+v, want the item appended", items)
+	}
+	if res := items[1].Reservations; len(res) != 1 || !res[0].Mine {
+		t.Fatalf("moved item reservations = %+v, want them kept", res)
+	}
+	if items := shared(birthday); len(items) != 1 || items[0].GiftId != lamp {
+		t.Fatalf("source after move = %+v", items)
+	}
+
+	var copied struct {
+		GiftIds []int `json:"gift_ids"`
+	}
+	code := a.do(http.MethodPost, "/api/items/copy", alice.AccessToken, map[string]any{
+		"wish_list_id": birthdayId,
+		"gift_ids":     []int{book, mug},
+	}, &copied)
+	if code != http.StatusOK || len(copied.GiftIds) != 2 {
+		t.Fatalf("copy: status %d, ids %v", code, copied.GiftIds)
+	}
+	items = shared(birthday)
+	if len(items) != 3 || items[1].GiftId != copied.GiftIds[0] || items[2].GiftId != copied.GiftIds[1] {
+		t.Fatalf("target after copy = %+v, want copies %v appended", items, copied.GiftIds)
+	}
+	if res := items[1].Reservations; len(res) != 0 || *items[1].Remaining != 1 {
+		t.Fatalf("copy of a reserved item = %+v, want no reservations", items[1])
+	}
+	if res := shared(newYear)[1].Reservations; len(res) != 1 {
+		t.Fatalf("copying dropped the original reservation: %+v", res)
+	}
+
+	if code := transfer("copy", alice.AccessToken, birthdayId, lamp); code != http.StatusConflict {
+		t.Fatalf("copy into the same list: status %d, want 409", code)
+	}
+	if code := transfer("move", alice.AccessToken, newYearId, copied.GiftIds[0]); code != http.StatusConflict {
+		t.Fatalf("move onto a duplicate url: status %d, want 409", code)
+	}
+	if code := transfer("move", alice.AccessToken, birthdayId, lamp); code != http.StatusOK {
+		t.Fatalf("move within the same list: status %d", code)
+	}
+	if items := shared(birthday); len(items) != 3 || items[0].GiftId != lamp {
+		t.Fatalf("moving into the current list changed the order: %+v", items)
+	}
+}
+
+func TestExternalUsers(t *testing.T) {
+	a := newAPI(t)
+	ctx := context.Background()
+
+	// A local account named after the identity must not be taken for it.
+	squatter := a.register("oidc:sub-1")
+
+	const logins = 8
+
+	uids := make(chan int, logins)
+	var wg sync.WaitGroup
+
+	for i := 0; i < logins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			uid, err := a.store.GetOrCreateExternalUser(ctx, "oidc", "sub-1", "alice@example.com")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			uids <- uid
+		}()
+	}
+	wg.Wait()
+	close(uids)
+
+	first := <-uids
+	for uid := range uids {
+		if uid != first {
+			t.Fatalf("concurrent first logins created users %d and %d", first, uid)
+		}
+	}
+
+	if code := a.do(http.MethodGet, "/api/wishlist/getforuser", squatter.AccessToken, nil, nil); code != http.StatusOK {
+		t.Fatalf("local account after sso login: status %d", code)
+	}
+
+	u, err := a.store.GetUserByLogin(ctx, "oidc:sub-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.UID == first {
+		t.Fatal("external identity resolved to the local account with the same login")
+	}
+
+	if _, err := a.store.GetUserByLogin(ctx, ""); err == nil {
+		t.Fatal("external user found by an empty login")
+	}
+}
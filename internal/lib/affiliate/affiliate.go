@@ -0,0 +1,160 @@
+// Package affiliate rewrites outbound item links into affiliate links
+// according to per-host rules from the config.
+package affiliate
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"wish_list/internal/config"
+	"wish_list/internal/entity"
+)
+
+var (
+	ErrInvalidRule   = errors.New("invalid affiliate rule")
+	ErrDuplicateHost = errors.New("duplicate affiliate rule host")
+)
+
+// placeholder marks where the escaped link goes in a rule template.
+const placeholder = "{url}"
+
+type rule struct {
+	params   map[string]string
+	template string
+}
+
+// Rewriter applies the configured rules. It is safe for concurrent use and
+// can be reloaded in place; a nil Rewriter leaves links unchanged.
+type Rewriter struct {
+	mu    sync.RWMutex
+	rules map[string]rule
+}
+
+func Load(cfg config.Affiliate) (*Rewriter, error) {
+	r := &Rewriter{}
+
+	if err := r.Reload(cfg); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload replaces the rules. The previous rules stay active if any of the
+// new ones is invalid.
+func (r *Rewriter) Reload(cfg config.Affiliate) error {
+	const op = "affiliate.Reload"
+
+	rules := make(map[string]rule, len(cfg.Rules))
+
+	for _, c := range cfg.Rules {
+		host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(c.Host)), ".")
+		if host == "" || strings.ContainsAny(host, "/:") {
+			return fmt.Errorf("%s: %w: host %q", op, ErrInvalidRule, c.Host)
+		}
+		if len(c.Params) == 0 && c.Template == "" {
+			return fmt.Errorf("%s: %w: %q sets neither params nor template", op, ErrInvalidRule, host)
+		}
+		if c.Template != "" && !validTemplate(c.Template) {
+			return fmt.Errorf("%s: %w: %q template must be an http or https URL containing %s", op, ErrInvalidRule, host, placeholder)
+		}
+		if _, ok := rules[host]; ok {
+			return fmt.Errorf("%s: %w: %q", op, ErrDuplicateHost, host)
+		}
+
+		rules[host] = rule{params: c.Params, template: c.Template}
+	}
+
+	r.mu.Lock()
+	r.rules = rules
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Len returns the number of active rules.
+func (r *Rewriter) Len() int {
+	if r == nil {
+		return 0
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.rules)
+}
+
+// Rewrite returns the affiliate form of link, or link itself when no rule
+// matches its host. The most specific rule wins: one for "smile.amazon.com"
+// is preferred over one for "amazon.com".
+func (r *Rewriter) Rewrite(link string) string {
+	if r == nil {
+		return link
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return link
+	}
+
+	rl, ok := r.lookup(strings.ToLower(u.Hostname()))
+	if !ok {
+		return link
+	}
+
+	if len(rl.params) > 0 {
+		q := u.Query()
+		for k, v := range rl.params {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	out := u.String()
+	if rl.template != "" {
+		out = strings.ReplaceAll(rl.template, placeholder, url.QueryEscape(out))
+	}
+
+	return out
+}
+
+// Apply rewrites the links of items shown to a user. The stored link of a
+// rewritten item is kept in SourceUrl.
+func (r *Rewriter) Apply(list []entity.GiftList) {
+	for i := range list {
+		if out := r.Rewrite(list[i].Url); out != list[i].Url {
+			list[i].SourceUrl = list[i].Url
+			list[i].Url = out
+		}
+	}
+}
+
+// lookup finds the rule for host or its closest parent domain.
+func (r *Rewriter) lookup(host string) (rule, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for {
+		if rl, ok := r.rules[host]; ok {
+			return rl, true
+		}
+
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			return rule{}, false
+		}
+		host = parent
+	}
+}
+
+func validTemplate(t string) bool {
+	if !strings.Contains(t, placeholder) {
+		return false
+	}
+
+	u, err := url.Parse(strings.ReplaceAll(t, placeholder, "x"))
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,108 @@
+package affiliate_test
+
+import (
+	"errors"
+	"testing"
+	"wish_list/internal/config"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/affiliate"
+)
+
+func TestRewrite(t *testing.T) {
+	r, err := affiliate.Load(config.Affiliate{Rules: []config.AffiliateRule{
+		{Host: "amazon.com", Params: map[string]string{"tag": "wish-20"}},
+		{Host: "smile.amazon.com", Params: map[string]string{"tag": "smile-20"}},
+		{Host: "OZON.ru", Template: "https://ad.example.net/g/abc/?ulp={url}&subid=wishlist"},
+		{Host: "shop.example", Params: map[string]string{"aff": "1"}, Template: "https://go.example.net/?to={url}"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", r.Len())
+	}
+
+	cases := map[string]string{
+		"https://www.amazon.com/dp/B01?th=1":     "https://www.amazon.com/dp/B01?tag=wish-20&th=1",
+		"https://amazon.com/dp/B01?tag=other-20": "https://amazon.com/dp/B01?tag=wish-20",
+		"https://smile.amazon.com/dp/B01":        "https://smile.amazon.com/dp/B01?tag=smile-20",
+		"https://www.ozon.ru/product/1/":         "https://ad.example.net/g/abc/?ulp=https%3A%2F%2Fwww.ozon.ru%2Fproduct%2F1%2F&subid=wishlist",
+		"https://shop.example/a":                 "https://go.example.net/?to=https%3A%2F%2Fshop.example%2Fa%3Faff%3D1",
+		"https://notamazon.com/dp/B01":           "https://notamazon.com/dp/B01",
+		"https://example.com/a":                  "https://example.com/a",
+		"handmade":                               "handmade",
+		"":                                       "",
+	}
+
+	for in, want := range cases {
+		if got := r.Rewrite(in); got != want {
+			t.Errorf("Rewrite(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	r, err := affiliate.Load(config.Affiliate{Rules: []config.AffiliateRule{
+		{Host: "amazon.com", Params: map[string]string{"tag": "wish-20"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := []entity.GiftList{
+		{Url: "https://www.amazon.com/dp/B01"},
+		{Url: "https://example.com/a"},
+	}
+	r.Apply(list)
+
+	if list[0].Url != "https://www.amazon.com/dp/B01?tag=wish-20" || list[0].SourceUrl != "https://www.amazon.com/dp/B01" {
+		t.Errorf("rewritten item = %+v", list[0])
+	}
+	if list[1].Url != "https://example.com/a" || list[1].SourceUrl != "" {
+		t.Errorf("untouched item = %+v", list[1])
+	}
+
+	var none *affiliate.Rewriter
+	if got := none.Rewrite("https://www.amazon.com/dp/B01"); got != "https://www.amazon.com/dp/B01" {
+		t.Errorf("nil rewriter changed the link: %q", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	r, err := affiliate.Load(config.Affiliate{Rules: []config.AffiliateRule{
+		{Host: "amazon.com", Params: map[string]string{"tag": "wish-20"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []struct {
+		rule config.AffiliateRule
+		want error
+	}{
+		{config.AffiliateRule{Host: "", Params: map[string]string{"a": "b"}}, affiliate.ErrInvalidRule},
+		{config.AffiliateRule{Host: "https://ozon.ru", Params: map[string]string{"a": "b"}}, affiliate.ErrInvalidRule},
+		{config.AffiliateRule{Host: "ozon.ru"}, affiliate.ErrInvalidRule},
+		{config.AffiliateRule{Host: "ozon.ru", Template: "https://ad.example.net/"}, affiliate.ErrInvalidRule},
+		{config.AffiliateRule{Host: "ozon.ru", Template: "javascript:{url}"}, affiliate.ErrInvalidRule},
+		{config.AffiliateRule{Host: "Amazon.com.", Params: map[string]string{"a": "b"}}, affiliate.ErrDuplicateHost},
+	}
+
+	for _, tc := range invalid {
+		rules := []config.AffiliateRule{{Host: "amazon.com", Params: map[string]string{"tag": "new-20"}}, tc.rule}
+		if err := r.Reload(config.Affiliate{Rules: rules}); !errors.Is(err, tc.want) {
+			t.Errorf("Reload(%+v) = %v, want %v", tc.rule, err, tc.want)
+		}
+	}
+
+	if got := r.Rewrite("https://amazon.com/dp/B01"); got != "https://amazon.com/dp/B01?tag=wish-20" {
+		t.Fatalf("failed reload changed the rules: %q", got)
+	}
+
+	if err := r.Reload(config.Affiliate{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Rewrite("https://amazon.com/dp/B01"); got != "https://amazon.com/dp/B01" {
+		t.Fatalf("rules kept after reload: %q", got)
+	}
+}
@@ -3,6 +3,7 @@ package response
 type Response struct {
 	Status string `json:"status"`
 	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"`
 }
 
 const (
@@ -22,3 +23,12 @@ func Error(msg string) Response {
 		Error:  msg,
 	}
 }
+
+// ErrorCode is Error with a machine readable reason the client can act on.
+func ErrorCode(code, msg string) Response {
+	return Response{
+		Status: StatusError,
+		Error:  msg,
+		Code:   code,
+	}
+}
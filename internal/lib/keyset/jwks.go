@@ -0,0 +1,148 @@
+package keyset
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported jwk")
+
+// jwk is the subset of RFC 7517 fields needed for signature verification.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+func readJWKS(path string) (map[string]key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwks: %w", err)
+	}
+
+	return parseJWKS(b)
+}
+
+// parseJWKS decodes a JSON Web Key Set into verification keys indexed by
+// kid. Keys marked for encryption are skipped.
+func parseJWKS(data []byte) (map[string]key, error) {
+	var set jwkSet
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+
+	keys := make(map[string]key, len(set.Keys))
+
+	for _, j := range set.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+
+		material, err := j.material()
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: %w", j.Kid, err)
+		}
+
+		if _, ok := keys[j.Kid]; ok {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateKey, j.Kid)
+		}
+
+		keys[j.Kid] = key{alg: j.Alg, material: material}
+	}
+
+	return keys, nil
+}
+
+func (j jwk) material() (any, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > math.MaxInt32 {
+			return nil, fmt.Errorf("%w: bad rsa exponent", ErrUnsupportedKey)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, j.Crv)
+		}
+		x, err := decodeInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("%w: point is not on curve", ErrUnsupportedKey)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: bad ed25519 key size", ErrUnsupportedKey)
+		}
+		return ed25519.PublicKey(x), nil
+
+	case "oct":
+		// A key set is public; a shared secret published in one would let
+		// anybody sign tokens. Secrets are configured as auth keys instead.
+		return nil, fmt.Errorf("%w: symmetric keys are not accepted from a jwks", ErrUnsupportedKey)
+	}
+
+	return nil, fmt.Errorf("%w: kty %q", ErrUnsupportedKey, j.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: empty integer", ErrUnsupportedKey)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
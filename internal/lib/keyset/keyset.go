@@ -0,0 +1,199 @@
+package keyset
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"wish_list/internal/config"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrNoKeys       = errors.New("no verification keys configured")
+	ErrUnknownKey   = errors.New("unknown signing key")
+	ErrAlgMismatch  = errors.New("signing method does not match key")
+	ErrEmptySecret  = errors.New("key secret is empty")
+	ErrDuplicateKey = errors.New("duplicate key id")
+)
+
+type key struct {
+	// alg is the exact algorithm the key is pinned to, empty if any
+	// algorithm of the key's family is accepted.
+	alg string
+	// material is []byte for HMAC keys, *rsa.PublicKey, *ecdsa.PublicKey or
+	// ed25519.PublicKey for asymmetric ones.
+	material any
+}
+
+// Set holds the keys used to verify incoming JWTs. Keys are looked up by the
+// token's kid header; tokens without kid match the key with an empty id.
+// A Set is safe for concurrent use and can be reloaded in place.
+type Set struct {
+	mu   sync.RWMutex
+	keys map[string]key
+}
+
+func Load(cfg config.Auth) (*Set, error) {
+	s := &Set{}
+
+	if err := s.Reload(cfg); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// FromJWKS builds a Set from a JSON Web Key Set document, such as the one
+// published by an OpenID provider.
+func FromJWKS(data []byte) (*Set, error) {
+	const op = "keyset.FromJWKS"
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, ErrNoKeys)
+	}
+
+	return &Set{keys: keys}, nil
+}
+
+// Reload re-reads every configured secret and the JWKS file. The previous
+// keys stay active if anything fails.
+func (s *Set) Reload(cfg config.Auth) error {
+	const op = "keyset.Reload"
+
+	keys := make(map[string]key)
+
+	for _, k := range cfg.Keys {
+		secret, err := readSecret(k)
+		if err != nil {
+			return fmt.Errorf("%s: key %q: %w", op, k.Kid, err)
+		}
+
+		if _, ok := keys[k.Kid]; ok {
+			return fmt.Errorf("%s: %w: %q", op, ErrDuplicateKey, k.Kid)
+		}
+
+		keys[k.Kid] = key{alg: k.Alg, material: secret}
+	}
+
+	if cfg.JWKSFile != "" {
+		jwks, err := readJWKS(cfg.JWKSFile)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for kid, k := range jwks {
+			if _, ok := keys[kid]; ok {
+				return fmt.Errorf("%s: %w: %q", op, ErrDuplicateKey, kid)
+			}
+
+			keys[kid] = k
+		}
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("%s: %w", op, ErrNoKeys)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Len returns the number of active keys.
+func (s *Set) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.keys)
+}
+
+// Secret returns the HMAC secret registered under kid. It is used to sign
+// tokens issued by the service itself.
+func (s *Set) Secret(kid string) ([]byte, bool) {
+	s.mu.RLock()
+	k, ok := s.keys[kid]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	secret, ok := k.material.([]byte)
+	return secret, ok
+}
+
+// Keyfunc selects the verification key for token and rejects tokens whose
+// signing method does not belong to the key type.
+func (s *Set) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	s.mu.RLock()
+	k, ok := s.keys[kid]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+	}
+
+	if k.alg != "" && k.alg != token.Method.Alg() {
+		return nil, fmt.Errorf("%w: %s", ErrAlgMismatch, token.Method.Alg())
+	}
+
+	var compatible bool
+
+	switch k.material.(type) {
+	case []byte:
+		_, compatible = token.Method.(*jwt.SigningMethodHMAC)
+	case *rsa.PublicKey:
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			compatible = true
+		}
+	case *ecdsa.PublicKey:
+		_, compatible = token.Method.(*jwt.SigningMethodECDSA)
+	case ed25519.PublicKey:
+		_, compatible = token.Method.(*jwt.SigningMethodEd25519)
+	}
+
+	if !compatible {
+		return nil, fmt.Errorf("%w: %s", ErrAlgMismatch, token.Method.Alg())
+	}
+
+	return k.material, nil
+}
+
+func readSecret(k config.Key) ([]byte, error) {
+	var secret string
+
+	switch {
+	case k.Secret != "":
+		secret = k.Secret
+	case k.SecretEnv != "":
+		secret = os.Getenv(k.SecretEnv)
+	case k.SecretFile != "":
+		b, err := os.ReadFile(k.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		secret = strings.TrimSpace(string(b))
+	}
+
+	if secret == "" {
+		return nil, ErrEmptySecret
+	}
+
+	return []byte(secret), nil
+}
@@ -0,0 +1,294 @@
+package keyset_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"wish_list/internal/config"
+	"wish_list/internal/lib/keyset"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeJWKS stores keys as a JWKS document and returns its path.
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+
+	b, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   b64(pub.N.Bytes()),
+		"e":   b64(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, jwt.MapClaims{"uid": 1})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// verify parses token with the keys of s and returns the error of the key
+// lookup or of the signature check.
+func verify(s *keyset.Set, token string) error {
+	_, err := jwt.Parse(token, s.Keyfunc)
+	return err
+}
+
+func TestKeyfunc(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := writeJWKS(t,
+		rsaJWK("rsa", &rsaKey.PublicKey),
+		map[string]string{
+			"kty": "EC", "kid": "ec", "crv": "P-256", "alg": "ES256",
+			"x": b64(ecKey.X.FillBytes(make([]byte, 32))),
+			"y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+		},
+		map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)},
+		map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	)
+
+	s, err := keyset.Load(config.Auth{
+		Keys: []config.Key{
+			{Kid: "", Secret: "default-secret"},
+			{Kid: "old", Secret: "old-secret"},
+			{Kid: "new", Alg: "HS256", Secret: "new-secret"},
+		},
+		JWKSFile: jwks,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 6 {
+		t.Fatalf("Len() = %d, want 6", s.Len())
+	}
+
+	accepted := map[string]string{
+		"no kid":  sign(t, jwt.SigningMethodHS256, "", []byte("default-secret")),
+		"old":     sign(t, jwt.SigningMethodHS512, "old", []byte("old-secret")),
+		"new":     sign(t, jwt.SigningMethodHS256, "new", []byte("new-secret")),
+		"rsa":     sign(t, jwt.SigningMethodRS256, "rsa", rsaKey),
+		"rsa-pss": sign(t, jwt.SigningMethodPS256, "rsa", rsaKey),
+		"ec":      sign(t, jwt.SigningMethodES256, "ec", ecKey),
+		"ed":      sign(t, jwt.SigningMethodEdDSA, "ed", edKey),
+	}
+	for name, token := range accepted {
+		if err := verify(s, token); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	rejected := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"unknown kid", sign(t, jwt.SigningMethodHS256, "gone", []byte("old-secret")), keyset.ErrUnknownKey},
+		{"skipped encryption key", sign(t, jwt.SigningMethodRS256, "enc", rsaKey), keyset.ErrUnknownKey},
+		{"pinned alg", sign(t, jwt.SigningMethodHS512, "new", []byte("new-secret")), keyset.ErrAlgMismatch},
+		{"hmac with an rsa key", sign(t, jwt.SigningMethodHS256, "rsa", []byte("anything")), keyset.ErrAlgMismatch},
+		{"rsa with an hmac key", sign(t, jwt.SigningMethodRS256, "old", rsaKey), keyset.ErrAlgMismatch},
+		{"ecdsa with an rsa key", sign(t, jwt.SigningMethodES256, "rsa", ecKey), keyset.ErrAlgMismatch},
+	}
+	for _, tc := range rejected {
+		if err := verify(s, tc.token); !errors.Is(err, tc.want) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	if err := verify(s, sign(t, jwt.SigningMethodHS256, "old", []byte("new-secret"))); !errors.Is(err, jwt.ErrSignatureInvalid) {
+		t.Errorf("wrong secret: %v, want invalid signature", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secretFile, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KEYSET_TEST_SECRET", "env-secret")
+
+	s, err := keyset.Load(config.Auth{Keys: []config.Key{
+		{Kid: "env", SecretEnv: "KEYSET_TEST_SECRET"},
+		{Kid: "file", SecretFile: secretFile},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envToken := sign(t, jwt.SigningMethodHS256, "env", []byte("env-secret"))
+	fileToken := sign(t, jwt.SigningMethodHS256, "file", []byte("file-secret"))
+
+	for _, token := range []string{envToken, fileToken} {
+		if err := verify(s, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rotation: the environment now holds a new secret and the file key is
+	// retired.
+	t.Setenv("KEYSET_TEST_SECRET", "rotated-secret")
+	if err := s.Reload(config.Auth{Keys: []config.Key{{Kid: "env", SecretEnv: "KEYSET_TEST_SECRET"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verify(s, envToken); !errors.Is(err, jwt.ErrSignatureInvalid) {
+		t.Errorf("token of the replaced secret: %v, want invalid signature", err)
+	}
+	if err := verify(s, fileToken); !errors.Is(err, keyset.ErrUnknownKey) {
+		t.Errorf("token of the removed key: %v, want unknown key", err)
+	}
+	rotated := sign(t, jwt.SigningMethodHS256, "env", []byte("rotated-secret"))
+	if err := verify(s, rotated); err != nil {
+		t.Fatalf("token of the new secret: %v", err)
+	}
+
+	invalid := []struct {
+		name string
+		cfg  config.Auth
+		want error
+	}{
+		{"no keys", config.Auth{}, keyset.ErrNoKeys},
+		{"empty env", config.Auth{Keys: []config.Key{{SecretEnv: "KEYSET_TEST_UNSET"}}}, keyset.ErrEmptySecret},
+		{"nothing set", config.Auth{Keys: []config.Key{{Kid: "a"}}}, keyset.ErrEmptySecret},
+		{"missing file", config.Auth{Keys: []config.Key{{SecretFile: filepath.Join(dir, "missing")}}}, os.ErrNotExist},
+		{"duplicate kid", config.Auth{Keys: []config.Key{{Kid: "a", Secret: "x"}, {Kid: "a", Secret: "y"}}}, keyset.ErrDuplicateKey},
+		{"missing jwks", config.Auth{Keys: []config.Key{{Secret: "x"}}, JWKSFile: filepath.Join(dir, "missing.json")}, os.ErrNotExist},
+	}
+	for _, tc := range invalid {
+		if err := s.Reload(tc.cfg); !errors.Is(err, tc.want) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	if err := verify(s, rotated); err != nil {
+		t.Fatalf("failed reload changed the keys: %v", err)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("Len() = %d after failed reloads, want 1", s.Len())
+	}
+}
+
+func TestBadJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := rsaJWK("rsa", &rsaKey.PublicKey)
+
+	cases := []struct {
+		name string
+		keys []map[string]string
+		want error
+	}{
+		{"no keys", nil, keyset.ErrNoKeys},
+		{"only encryption keys", []map[string]string{{"kty": "RSA", "use": "enc"}}, keyset.ErrNoKeys},
+		{"unknown kty", []map[string]string{{"kty": "XYZ", "kid": "x"}}, keyset.ErrUnsupportedKey},
+		{"empty modulus", []map[string]string{{"kty": "RSA", "kid": "x", "e": "AQAB"}}, keyset.ErrUnsupportedKey},
+		{"huge exponent", []map[string]string{{"kty": "RSA", "kid": "x", "n": good["n"], "e": b64(append([]byte{1}, make([]byte, 8)...))}}, keyset.ErrUnsupportedKey},
+		{"exponent of one", []map[string]string{{"kty": "RSA", "kid": "x", "n": good["n"], "e": "AQ"}}, keyset.ErrUnsupportedKey},
+		{"unknown curve", []map[string]string{{"kty": "EC", "kid": "x", "crv": "P-192"}}, keyset.ErrUnsupportedKey},
+		{"point off the curve", []map[string]string{{"kty": "EC", "kid": "x", "crv": "P-256", "x": "AQ", "y": "AQ"}}, keyset.ErrUnsupportedKey},
+		{"short ed25519 key", []map[string]string{{"kty": "OKP", "kid": "x", "crv": "Ed25519", "x": "AQID"}}, keyset.ErrUnsupportedKey},
+		{"x25519 key", []map[string]string{{"kty": "OKP", "kid": "x", "crv": "X25519", "x": b64(make([]byte, 32))}}, keyset.ErrUnsupportedKey},
+		{"hmac secret", []map[string]string{good, {"kty": "oct", "kid": "hs", "k": b64([]byte("secret"))}}, keyset.ErrUnsupportedKey},
+		{"duplicate kid", []map[string]string{good, good}, keyset.ErrDuplicateKey},
+	}
+
+	for _, tc := range cases {
+		data, err := json.Marshal(map[string]any{"keys": tc.keys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := keyset.FromJWKS(data); !errors.Is(err, tc.want) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	if _, err := keyset.FromJWKS([]byte(`{"keys": [`)); err == nil {
+		t.Error("truncated document accepted")
+	}
+	if _, err := keyset.FromJWKS([]byte(`{"keys": [{"kty": "RSA", "kid": "x", "n": "!!", "e": "AQAB"}]}`)); err == nil {
+		t.Error("invalid base64 accepted")
+	}
+}
+
+func TestJWKSCarriesNoSecrets(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := keyset.Load(config.Auth{
+		Keys:     []config.Key{{Kid: "hs", Secret: "local-secret"}},
+		JWKSFile: writeJWKS(t, rsaJWK("rsa", &rsaKey.PublicKey)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.Secret("rsa"); ok {
+		t.Error("a jwks key is usable as a signing secret")
+	}
+	if secret, ok := s.Secret("hs"); !ok || string(secret) != "local-secret" {
+		t.Errorf("Secret(hs) = %q, %v", secret, ok)
+	}
+
+	// An HMAC key in the key set file would let anyone holding the public
+	// document sign tokens, so the whole reload is refused.
+	jwks := writeJWKS(t, rsaJWK("rsa", &rsaKey.PublicKey), map[string]string{"kty": "oct", "kid": "leak", "k": b64([]byte("secret"))})
+	if err := s.Reload(config.Auth{Keys: []config.Key{{Kid: "hs", Secret: "local-secret"}}, JWKSFile: jwks}); !errors.Is(err, keyset.ErrUnsupportedKey) {
+		t.Fatalf("reload with an oct jwk: %v, want %v", err, keyset.ErrUnsupportedKey)
+	}
+	if _, ok := s.Secret("leak"); ok {
+		t.Fatal("oct jwk became a signing secret")
+	}
+}
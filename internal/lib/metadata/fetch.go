@@ -0,0 +1,249 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrUnsupportedURL   = errors.New("only absolute http and https URLs can be fetched")
+	ErrForbiddenAddress = errors.New("address is not publicly routable")
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrBadStatus        = errors.New("unexpected response status")
+	ErrNotHTML          = errors.New("response is not an HTML document")
+	ErrTooLarge         = errors.New("response exceeds the size limit")
+)
+
+// Options tune a Fetcher. Zero values fall back to the defaults.
+type Options struct {
+	Timeout      time.Duration // whole request, body included; default 5s
+	MaxBytes     int64         // largest accepted page; default 2 MiB
+	MaxRedirects int           // default 5
+	UserAgent    string
+	// Parsers adds shop-specific parsing on top of the generic metadata.
+	Parsers *Registry
+	// AllowPrivate lets requests reach loopback and private networks. It is
+	// meant for tests and local development only.
+	AllowPrivate bool
+}
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultMaxBytes     = 2 << 20
+	defaultMaxRedirects = 5
+	defaultUserAgent    = "wish_list-metadata/1.0"
+)
+
+// Fetcher downloads product pages and extracts their metadata. Every
+// connection, including those made while following redirects, is checked
+// against the resolved IP address, so hostnames pointing into private
+// networks are refused as well.
+type Fetcher struct {
+	client    *http.Client
+	probe     *http.Client // same transport, never follows redirects
+	parsers   *Registry
+	maxBytes  int64
+	userAgent string
+}
+
+func NewFetcher(opts Options) *Fetcher {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultMaxBytes
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = defaultMaxRedirects
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = defaultUserAgent
+	}
+
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	if !opts.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsPublic(ip) {
+				return fmt.Errorf("%s: %w", host, ErrForbiddenAddress)
+			}
+			return nil
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil, // a proxy would dial on our behalf and bypass the address check
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+	}
+
+	maxRedirects := opts.MaxRedirects
+
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return ErrTooManyRedirects
+				}
+				if !httpURL(req.URL) {
+					return ErrUnsupportedURL
+				}
+				return nil
+			},
+		},
+		probe: &http.Client{
+			Transport: transport,
+			Timeout:   opts.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		parsers:   opts.Parsers,
+		maxBytes:  opts.MaxBytes,
+		userAgent: opts.UserAgent,
+	}
+}
+
+// Fetch downloads the page at rawURL and parses it.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Product, error) {
+	const op = "metadata.Fetch"
+
+	body, pageURL, err := f.Get(ctx, rawURL)
+	if err != nil {
+		return Product{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p, err := f.parsers.Parse(bytes.NewReader(body), pageURL)
+	if err != nil {
+		return Product{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return p, nil
+}
+
+// Get downloads the HTML page at rawURL within the configured limits and
+// returns its body together with the URL it was finally served from.
+func (f *Fetcher) Get(ctx context.Context, rawURL string) ([]byte, *url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !httpURL(u) {
+		return nil, nil, ErrUnsupportedURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%w: %d", ErrBadStatus, res.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, nil, fmt.Errorf("%w: %q", ErrNotHTML, mediaType)
+	}
+
+	if res.ContentLength > f.maxBytes {
+		return nil, nil, ErrTooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(body)) > f.maxBytes {
+		return nil, nil, ErrTooLarge
+	}
+
+	return body, res.Request.URL, nil
+}
+
+// Probe requests rawURL without following redirects and returns the
+// response status. The body is not read.
+func (f *Fetcher) Probe(ctx context.Context, rawURL string) (int, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !httpURL(u) {
+		return 0, ErrUnsupportedURL
+	}
+
+	// GET rather than HEAD: many shops answer HEAD with 405 or 404.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	res, err := f.probe.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	return res.StatusCode, nil
+}
+
+// reserved lists special-purpose ranges not covered by the net.IP helpers.
+var reserved = []*net.IPNet{
+	cidr("0.0.0.0/8"),     // "this" network
+	cidr("100.64.0.0/10"), // carrier-grade NAT
+	cidr("192.0.0.0/24"),  // IETF protocol assignments
+	cidr("198.18.0.0/15"), // benchmarking
+	cidr("240.0.0.0/4"),   // reserved, including broadcast
+	cidr("64:ff9b::/96"),  // NAT64, may embed a private IPv4 address
+	cidr("2001:db8::/32"), // documentation
+}
+
+// IsPublic reports whether ip is a globally routable unicast address.
+func IsPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	for _, n := range reserved {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func httpURL(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func cidr(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
@@ -0,0 +1,57 @@
+package marketplace
+
+import (
+	"golang.org/x/net/html"
+	"net/url"
+	"wish_list/internal/lib/metadata"
+)
+
+// amazonCurrencies maps Amazon storefronts to the currency they sell in.
+var amazonCurrencies = map[string]string{
+	"amazon.com":    "USD",
+	"amazon.ca":     "CAD",
+	"amazon.com.mx": "MXN",
+	"amazon.com.br": "BRL",
+	"amazon.co.uk":  "GBP",
+	"amazon.de":     "EUR",
+	"amazon.fr":     "EUR",
+	"amazon.it":     "EUR",
+	"amazon.es":     "EUR",
+	"amazon.nl":     "EUR",
+	"amazon.pl":     "PLN",
+	"amazon.se":     "SEK",
+	"amazon.com.tr": "TRY",
+	"amazon.ae":     "AED",
+	"amazon.in":     "INR",
+	"amazon.co.jp":  "JPY",
+	"amazon.com.au": "AUD",
+	"amazon.sg":     "SGD",
+}
+
+// amazon returns the parser of a storefront selling in currency.
+func amazon(currency string) metadata.Parser {
+	return metadata.ParserFunc(func(doc *html.Node, _ *url.URL) metadata.Product {
+		var p metadata.Product
+
+		p.Title = text(find(doc, withId("productTitle")))
+
+		for _, block := range []string{"corePrice_feature_div", "corePriceDisplay_desktop_feature_div", "apex_desktop"} {
+			if n := find(doc, withId(block)); n != nil {
+				if price := find(n, withClass("span", "a-offscreen")); price != nil {
+					p.Price = parsePrice(text(price), currency)
+					break
+				}
+			}
+		}
+		if p.Price == nil {
+			if n := findAny(doc, withId("priceblock_ourprice"), withId("priceblock_dealprice")); n != nil {
+				p.Price = parsePrice(text(n), currency)
+			}
+		}
+
+		image := find(doc, withId("landingImage"))
+		p.ImageUrl = first(attr(image, "data-old-hires"), attr(image, "src"))
+
+		return p
+	})
+}
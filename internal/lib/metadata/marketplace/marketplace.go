@@ -0,0 +1,176 @@
+// Package marketplace holds product page parsers for shops whose generic
+// page metadata is incomplete: Ozon, Wildberries, Yandex Market and Amazon.
+package marketplace
+
+import (
+	"golang.org/x/net/html"
+	"strings"
+	"unicode"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/metadata"
+	"wish_list/internal/lib/money"
+)
+
+// Register adds the parsers of all supported shops to r.
+func Register(r *metadata.Registry) {
+	r.Register("ozon.ru", metadata.ParserFunc(ozon))
+	r.Register("wildberries.ru", metadata.ParserFunc(wildberries))
+	r.Register("wb.ru", metadata.ParserFunc(wildberries))
+	r.Register("market.yandex.ru", metadata.ParserFunc(yandexMarket))
+
+	for host, currency := range amazonCurrencies {
+		r.Register(host, amazon(currency))
+	}
+}
+
+// Default returns a registry with the parsers of all supported shops.
+func Default() *metadata.Registry {
+	r := metadata.NewRegistry()
+	Register(r)
+	return r
+}
+
+// symbols maps currency signs that identify a single currency. Ambiguous
+// ones, such as "$" or "¥", fall back to the shop's default currency.
+var symbols = []struct {
+	sign     string
+	currency string
+}{
+	{"₽", "RUB"}, {"руб", "RUB"}, {"₸", "KZT"}, {"€", "EUR"}, {"£", "GBP"},
+	{"₴", "UAH"}, {"₹", "INR"}, {"₺", "TRY"}, {"zł", "PLN"},
+}
+
+// parsePrice reads a price as displayed by a shop, such as "1 299 ₽" or
+// "$89.99". The currency is taken from the text when it names one
+// unambiguously and is fallback otherwise.
+func parsePrice(text, fallback string) *entity.Price {
+	currency := fallback
+	for _, s := range symbols {
+		if strings.Contains(text, s.sign) {
+			currency = s.currency
+			break
+		}
+	}
+
+	amount := number(text)
+	if amount == "" {
+		return nil
+	}
+
+	minor, ok := money.Parse(amount, currency)
+	if !ok {
+		return nil
+	}
+
+	return &entity.Price{Amount: minor, Currency: currency}
+}
+
+// number returns the first run of digits, spaces and separators in s with
+// trailing separators removed.
+func number(s string) string {
+	start := strings.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' })
+	if start < 0 {
+		return ""
+	}
+
+	end := strings.IndexFunc(s[start:], func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r == '.' || r == ',' || r == '\'' || unicode.IsSpace(r))
+	})
+	if end < 0 {
+		end = len(s) - start
+	}
+
+	return strings.TrimRightFunc(s[start:start+end], func(r rune) bool {
+		return r == '.' || r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// find returns the first element under n, in document order, that matches.
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := find(c, match); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// findAny tries the matchers in order and returns the first element found.
+func findAny(n *html.Node, matchers ...func(*html.Node) bool) *html.Node {
+	for _, m := range matchers {
+		if found := find(n, m); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func withId(id string) func(*html.Node) bool {
+	return func(n *html.Node) bool { return attr(n, "id") == id }
+}
+
+func withClass(tag, class string) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		if tag != "" && n.Data != tag {
+			return false
+		}
+		for _, c := range strings.Fields(attr(n, "class")) {
+			if c == class {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func withAttr(key, value string) func(*html.Node) bool {
+	return func(n *html.Node) bool { return attr(n, key) == value }
+}
+
+func attr(n *html.Node, key string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// text returns the text content of n and its descendants.
+func text(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+
+	var b strings.Builder
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return strings.TrimSpace(b.String())
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
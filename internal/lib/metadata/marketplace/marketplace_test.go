@@ -0,0 +1,93 @@
+package marketplace_test
+
+import (
+	"encoding/json"
+	"flag"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"wish_list/internal/lib/metadata/marketplace"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// TestGolden parses saved shop pages and compares the result with the
+// matching testdata/*.golden.json. Run with -update after changing a parser.
+func TestGolden(t *testing.T) {
+	cases := []struct {
+		fixture string
+		url     string
+	}{
+		{"ozon", "https://www.ozon.ru/product/nastolnaya-igra-karkasson-138285475/"},
+		{"wildberries", "https://www.wildberries.ru/catalog/175328004/detail.aspx"},
+		{"wildberries_microdata", "https://www.wildberries.ru/catalog/81244731/detail.aspx"},
+		{"yandex_market", "https://market.yandex.ru/product--smartfon-apple-iphone-15/1234"},
+		{"amazon_com", "https://www.amazon.com/dp/B08KTZ8249"},
+		{"amazon_de", "https://www.amazon.de/dp/B01M0HSJPW"},
+	}
+
+	registry := marketplace.Default()
+
+	for _, tc := range cases {
+		t.Run(tc.fixture, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tc.fixture+".html"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			pageURL, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := registry.Parse(f, pageURL)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			got, err := json.MarshalIndent(p, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", tc.fixture+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	registry := marketplace.Default()
+
+	for host, want := range map[string]bool{
+		"www.ozon.ru":         true,
+		"m.ozon.ru":           true,
+		"ozon.ru:443":         true,
+		"WWW.Wildberries.RU":  true,
+		"market.yandex.ru":    true,
+		"yandex.ru":           false,
+		"www.amazon.co.uk":    true,
+		"smile.amazon.com":    true,
+		"amazon.com.evil.org": false,
+		"notozon.ru":          false,
+	} {
+		if _, got := registry.Lookup(host); got != want {
+			t.Errorf("Lookup(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package marketplace
+
+import (
+	"encoding/json"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+	"wish_list/internal/lib/metadata"
+)
+
+// ozon reads the JSON state Ozon renders into the data-state attribute of
+// its page widgets; the widget ids carry a per-page suffix.
+func ozon(doc *html.Node, _ *url.URL) metadata.Product {
+	var p metadata.Product
+
+	var heading struct {
+		Title string `json:"title"`
+	}
+	if widgetState(doc, "state-webProductHeading-", &heading) {
+		p.Title = heading.Title
+	}
+
+	var price struct {
+		Price     string `json:"price"`
+		CardPrice string `json:"cardPrice"`
+	}
+	if widgetState(doc, "state-webPrice-", &price) {
+		p.Price = parsePrice(first(price.Price, price.CardPrice), "RUB")
+	}
+
+	var gallery struct {
+		CoverImage string `json:"coverImage"`
+		Images     []struct {
+			Src string `json:"src"`
+		} `json:"images"`
+	}
+	if widgetState(doc, "state-webGallery-", &gallery) {
+		p.ImageUrl = gallery.CoverImage
+		if p.ImageUrl == "" && len(gallery.Images) > 0 {
+			p.ImageUrl = gallery.Images[0].Src
+		}
+	}
+
+	return p
+}
+
+// widgetState decodes the data-state of the first element whose id starts
+// with prefix.
+func widgetState(doc *html.Node, prefix string, v any) bool {
+	n := find(doc, func(n *html.Node) bool { return strings.HasPrefix(attr(n, "id"), prefix) })
+	if n == nil {
+		return false
+	}
+
+	return json.Unmarshal([]byte(attr(n, "data-state")), v) == nil
+}
@@ -0,0 +1,34 @@
+package marketplace
+
+import (
+	"golang.org/x/net/html"
+	"net/url"
+	"wish_list/internal/lib/metadata"
+)
+
+// wildberries reads the product card markup, falling back to the
+// schema.org microdata Wildberries adds to the price block.
+func wildberries(doc *html.Node, _ *url.URL) metadata.Product {
+	var p metadata.Product
+
+	p.Title = text(findAny(doc,
+		withClass("h1", "product-page__title"),
+		withClass("", "product-page__header"),
+	))
+
+	if n := findAny(doc, withClass("", "price-block__final-price"), withClass("", "price-block__wallet-price")); n != nil {
+		p.Price = parsePrice(text(n), "RUB")
+	}
+	if p.Price == nil {
+		amount := attr(find(doc, withAttr("itemprop", "price")), "content")
+		currency := first(attr(find(doc, withAttr("itemprop", "priceCurrency")), "content"), "RUB")
+		if amount != "" {
+			p.Price = parsePrice(amount, currency)
+		}
+	}
+
+	image := findAny(doc, withClass("img", "photo-zoom__preview"), withClass("img", "j-zoom-image"))
+	p.ImageUrl = first(attr(image, "src"), attr(image, "data-src"))
+
+	return p
+}
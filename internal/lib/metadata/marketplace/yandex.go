@@ -0,0 +1,26 @@
+package marketplace
+
+import (
+	"golang.org/x/net/html"
+	"net/url"
+	"wish_list/internal/lib/metadata"
+)
+
+// yandexMarket reads the data-auto markers Yandex Market puts on the parts
+// of a product card.
+func yandexMarket(doc *html.Node, _ *url.URL) metadata.Product {
+	var p metadata.Product
+
+	p.Title = text(find(doc, withAttr("data-auto", "productCardTitle")))
+
+	if n := findAny(doc, withAttr("data-auto", "snippet-price-current"), withAttr("data-auto", "price-value")); n != nil {
+		p.Price = parsePrice(text(n), "RUB")
+	}
+
+	if viewer := find(doc, withAttr("data-auto", "media-viewer")); viewer != nil {
+		image := find(viewer, func(n *html.Node) bool { return n.Data == "img" })
+		p.ImageUrl = attr(image, "src")
+	}
+
+	return p
+}
@@ -0,0 +1,289 @@
+// Package metadata reads product details from web pages: OpenGraph and
+// Twitter card meta tags and schema.org Product JSON-LD.
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/money"
+)
+
+const (
+	maxTitleLen = 200
+	maxUrlLen   = 2048
+)
+
+// Product is what a page tells about the product it sells. Fields the page
+// does not provide are left empty.
+type Product struct {
+	Title    string
+	ImageUrl string
+	Price    *entity.Price
+}
+
+// Parse extracts product details from an HTML document served at pageURL.
+// JSON-LD takes precedence over OpenGraph, which takes precedence over
+// Twitter cards; the <title> element is the last resort for the title.
+// Relative image URLs are resolved against pageURL.
+func Parse(r io.Reader, pageURL *url.URL) (Product, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return Product{}, err
+	}
+
+	return parseDoc(doc, pageURL), nil
+}
+
+func parseDoc(doc *html.Node, pageURL *url.URL) Product {
+	var p page
+	p.meta = make(map[string]string)
+	p.walk(doc)
+
+	ld := p.jsonLD()
+
+	var out Product
+
+	out.Title = cleanTitle(first(ld.Title, p.meta["og:title"], p.meta["twitter:title"], p.title))
+
+	for _, ref := range []string{ld.ImageUrl, p.meta["og:image:secure_url"], p.meta["og:image"], p.meta["twitter:image"], p.meta["twitter:image:src"]} {
+		if out.ImageUrl = resolve(pageURL, ref); out.ImageUrl != "" {
+			break
+		}
+	}
+
+	out.Price = ld.Price
+	if out.Price == nil {
+		out.Price = price(first(p.meta["product:price:amount"], p.meta["og:price:amount"]),
+			first(p.meta["product:price:currency"], p.meta["og:price:currency"]))
+	}
+
+	return out
+}
+
+// page collects the parts of a document Parse looks at.
+type page struct {
+	title   string
+	meta    map[string]string // property or name → content, first one wins
+	scripts []string          // application/ld+json bodies
+}
+
+func (p *page) walk(n *html.Node) {
+	if n.Type == html.ElementNode {
+		switch n.DataAtom {
+		case atom.Title:
+			if p.title == "" {
+				p.title = text(n)
+			}
+		case atom.Meta:
+			key := strings.ToLower(first(attr(n, "property"), attr(n, "name")))
+			if _, seen := p.meta[key]; key != "" && !seen {
+				p.meta[key] = strings.TrimSpace(attr(n, "content"))
+			}
+		case atom.Script:
+			if strings.EqualFold(strings.TrimSpace(attr(n, "type")), "application/ld+json") {
+				p.scripts = append(p.scripts, text(n))
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.walk(c)
+	}
+}
+
+// jsonLD returns the details of the first schema.org Product found in the
+// page's JSON-LD blocks. Malformed blocks are skipped.
+func (p *page) jsonLD() Product {
+	for _, script := range p.scripts {
+		dec := json.NewDecoder(strings.NewReader(script))
+		dec.UseNumber()
+
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			continue
+		}
+
+		if node := findProduct(v); node != nil {
+			return Product{
+				Title:    str(node["name"]),
+				ImageUrl: image(node["image"]),
+				Price:    offerPrice(node["offers"]),
+			}
+		}
+	}
+
+	return Product{}
+}
+
+// findProduct searches a decoded JSON-LD value, including @graph lists and
+// nested objects, for a node typed Product.
+func findProduct(v any) map[string]any {
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			if node := findProduct(e); node != nil {
+				return node
+			}
+		}
+	case map[string]any:
+		if isProduct(v["@type"]) {
+			return v
+		}
+		for _, e := range v {
+			if node := findProduct(e); node != nil {
+				return node
+			}
+		}
+	}
+
+	return nil
+}
+
+func isProduct(t any) bool {
+	switch t := t.(type) {
+	case string:
+		return t == "Product" || t == "http://schema.org/Product" || t == "https://schema.org/Product"
+	case []any:
+		for _, e := range t {
+			if isProduct(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// image reads a schema.org image, which may be a URL, an ImageObject or a
+// list of either.
+func image(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		for _, e := range v {
+			if s := image(e); s != "" {
+				return s
+			}
+		}
+	case map[string]any:
+		return first(str(v["url"]), str(v["contentUrl"]))
+	}
+	return ""
+}
+
+// offerPrice reads the price of an Offer, an AggregateOffer or the first
+// priced offer of a list.
+func offerPrice(v any) *entity.Price {
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			if p := offerPrice(e); p != nil {
+				return p
+			}
+		}
+	case map[string]any:
+		currency := str(v["priceCurrency"])
+		if p := price(first(str(v["price"]), str(v["lowPrice"])), currency); p != nil {
+			return p
+		}
+		if spec, ok := v["priceSpecification"].(map[string]any); ok {
+			return price(str(spec["price"]), first(str(spec["priceCurrency"]), currency))
+		}
+	}
+	return nil
+}
+
+// price converts a decimal amount and a currency code into an entity.Price,
+// or nil when either is missing or invalid.
+func price(amount, currency string) *entity.Price {
+	currency, ok := money.Normalize(currency)
+	if !ok || amount == "" {
+		return nil
+	}
+
+	minor, ok := money.Parse(amount, currency)
+	if !ok {
+		return nil
+	}
+
+	return &entity.Price{Amount: minor, Currency: currency}
+}
+
+// resolve turns ref into an absolute http(s) URL, or "" when it cannot.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(u.String()) > maxUrlLen {
+		return ""
+	}
+
+	return u.String()
+}
+
+// cleanTitle collapses whitespace and cuts the title to the item name limit.
+func cleanTitle(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+
+	if utf8.RuneCountInString(s) > maxTitleLen {
+		s = strings.TrimSpace(string([]rune(s)[:maxTitleLen]))
+	}
+
+	return s
+}
+
+func text(n *html.Node) string {
+	var buf bytes.Buffer
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			buf.WriteString(c.Data)
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// str returns JSON strings and numbers as text.
+func str(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
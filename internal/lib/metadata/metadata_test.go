@@ -0,0 +1,236 @@
+package metadata_test
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/net/html"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/metadata"
+)
+
+const openGraphPage = `<!doctype html>
+<html><head>
+<title>Shop | Kettle</title>
+<meta property="og:title" content="Electric kettle  1.7 L">
+<meta property="og:image" content="/img/kettle.jpg">
+<meta property="product:price:amount" content="2 499,90">
+<meta property="product:price:currency" content="rub">
+<meta name="twitter:title" content="Kettle on Twitter">
+</head><body></body></html>`
+
+const twitterPage = `<html><head>
+<title>fallback</title>
+<meta name="twitter:title" content="Desk lamp">
+<meta name="twitter:image" content="https://cdn.example.com/lamp.png">
+</head></html>`
+
+const jsonLDPage = `<html><head>
+<meta property="og:title" content="OG title loses">
+<meta property="og:image" content="https://example.com/og.jpg">
+<script type="application/ld+json">{"@context":"https://schema.org","@type":"BreadcrumbList"}</script>
+<script type="application/ld+json">not json</script>
+<script type="application/ld+json">
+{"@context":"https://schema.org","@graph":[
+  {"@type":"WebPage","name":"page"},
+  {"@type":["Product","Thing"],"name":"Running shoes",
+   "image":[{"@type":"ImageObject","url":"https://cdn.example.com/shoes.webp"}],
+   "offers":[{"@type":"Offer","availability":"InStock"},
+             {"@type":"Offer","price":89.5,"priceCurrency":"USD"}]}
+]}
+</script>
+</head></html>`
+
+const aggregatePage = `<html><head><script type="application/ld+json">
+{"@type":"Product","name":"Headphones","image":"//cdn.example.com/h.jpg",
+ "offers":{"@type":"AggregateOffer","lowPrice":"1999","priceCurrency":"JPY"}}
+</script></head></html>`
+
+func TestFetchParsesPages(t *testing.T) {
+	pages := map[string]string{
+		"/og":        openGraphPage,
+		"/twitter":   twitterPage,
+		"/jsonld":    jsonLDPage,
+		"/aggregate": aggregatePage,
+		"/plain":     `<html><head><title> Just   a title </title></head></html>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		path string
+		want metadata.Product
+	}{
+		{"/og", metadata.Product{
+			Title:    "Electric kettle 1.7 L",
+			ImageUrl: srv.URL + "/img/kettle.jpg",
+			Price:    &entity.Price{Amount: 249990, Currency: "RUB"},
+		}},
+		{"/twitter", metadata.Product{Title: "Desk lamp", ImageUrl: "https://cdn.example.com/lamp.png"}},
+		{"/jsonld", metadata.Product{
+			Title:    "Running shoes",
+			ImageUrl: "https://cdn.example.com/shoes.webp",
+			Price:    &entity.Price{Amount: 8950, Currency: "USD"},
+		}},
+		{"/aggregate", metadata.Product{
+			Title:    "Headphones",
+			ImageUrl: "http://cdn.example.com/h.jpg",
+			Price:    &entity.Price{Amount: 1999, Currency: "JPY"},
+		}},
+		{"/plain", metadata.Product{Title: "Just a title"}},
+	}
+
+	f := metadata.NewFetcher(metadata.Options{AllowPrivate: true})
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			got, err := f.Fetch(context.Background(), srv.URL+tc.path)
+			if err != nil {
+				t.Fatalf("fetch: %v", err)
+			}
+			if got.Title != tc.want.Title || got.ImageUrl != tc.want.ImageUrl {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			if (got.Price == nil) != (tc.want.Price == nil) || got.Price != nil && *got.Price != *tc.want.Price {
+				t.Fatalf("price = %+v, want %+v", got.Price, tc.want.Price)
+			}
+		})
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>" + strings.Repeat("x", 2048) + "</html>"))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		case "/missing":
+			http.NotFound(w, r)
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/ftp":
+			http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	f := metadata.NewFetcher(metadata.Options{
+		AllowPrivate: true,
+		MaxBytes:     1024,
+		Timeout:      100 * time.Millisecond,
+	})
+
+	cases := []struct {
+		url  string
+		want error
+	}{
+		{srv.URL + "/big", metadata.ErrTooLarge},
+		{srv.URL + "/json", metadata.ErrNotHTML},
+		{srv.URL + "/missing", metadata.ErrBadStatus},
+		{srv.URL + "/loop", metadata.ErrTooManyRedirects},
+		{srv.URL + "/ftp", metadata.ErrUnsupportedURL},
+		{"file:///etc/passwd", metadata.ErrUnsupportedURL},
+		{"/relative", metadata.ErrUnsupportedURL},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.url, func(t *testing.T) {
+			if _, err := f.Fetch(context.Background(), tc.url); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		var netErr net.Error
+		if _, err := f.Fetch(context.Background(), srv.URL+"/slow"); !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Fatalf("err = %v, want a timeout", err)
+		}
+	})
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.Redirect(w, r, "http://127.0.0.1:1/", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	f := metadata.NewFetcher(metadata.Options{})
+
+	for _, u := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1), "http://[::1]:1/"} {
+		if _, err := f.Fetch(context.Background(), u); !errors.Is(err, metadata.ErrForbiddenAddress) {
+			t.Fatalf("%s: err = %v, want %v", u, err, metadata.ErrForbiddenAddress)
+		}
+	}
+	if hits != 0 {
+		t.Fatalf("server was reached %d times", hits)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	}
+
+	for addr, want := range cases {
+		if got := metadata.IsPublic(net.ParseIP(addr)); got != want {
+			t.Errorf("IsPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestFetchUsesRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(openGraphPage))
+	}))
+	defer srv.Close()
+
+	registry := metadata.NewRegistry()
+	registry.Register("127.0.0.1", metadata.ParserFunc(func(doc *html.Node, _ *url.URL) metadata.Product {
+		return metadata.Product{Title: "  Shop   title ", ImageUrl: "/shop.jpg"}
+	}))
+
+	f := metadata.NewFetcher(metadata.Options{AllowPrivate: true, Parsers: registry})
+
+	got, err := f.Fetch(context.Background(), srv.URL+"/item")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	want := metadata.Product{Title: "Shop title", ImageUrl: srv.URL + "/shop.jpg"}
+	if got.Title != want.Title || got.ImageUrl != want.ImageUrl {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got.Price == nil || *got.Price != (entity.Price{Amount: 249990, Currency: "RUB"}) {
+		t.Fatalf("price = %+v, want the generic one", got.Price)
+	}
+}
@@ -0,0 +1,97 @@
+package metadata
+
+import (
+	"golang.org/x/net/html"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Parser extracts product details from the pages of a particular shop. The
+// registry cleans up the title and resolves the image URL it returns.
+type Parser interface {
+	Parse(doc *html.Node, pageURL *url.URL) Product
+}
+
+// ParserFunc adapts a function to the Parser interface.
+type ParserFunc func(doc *html.Node, pageURL *url.URL) Product
+
+func (f ParserFunc) Parse(doc *html.Node, pageURL *url.URL) Product {
+	return f(doc, pageURL)
+}
+
+// Registry picks a shop-specific Parser by the host of the page. A parser
+// registered for "ozon.ru" also serves "www.ozon.ru" and "m.ozon.ru".
+// Parsers are registered at start-up; Register must not race with Parse.
+type Registry struct {
+	parsers map[string]Parser
+}
+
+func NewRegistry() *Registry {
+	return &Registry{parsers: make(map[string]Parser)}
+}
+
+func (r *Registry) Register(host string, p Parser) {
+	r.parsers[strings.ToLower(host)] = p
+}
+
+// Lookup returns the parser registered for host or the closest parent
+// domain.
+func (r *Registry) Lookup(host string) (Parser, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	for host != "" {
+		if p, ok := r.parsers[host]; ok {
+			return p, true
+		}
+
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+
+	return nil, false
+}
+
+// Parse reads the document with the parser registered for the page's host
+// and fills whatever it misses from the generic page metadata. A nil
+// Registry only parses generically.
+func (r *Registry) Parse(rd io.Reader, pageURL *url.URL) (Product, error) {
+	doc, err := html.Parse(rd)
+	if err != nil {
+		return Product{}, err
+	}
+
+	generic := parseDoc(doc, pageURL)
+
+	if pageURL == nil {
+		return generic, nil
+	}
+	p, ok := r.Lookup(pageURL.Host)
+	if !ok {
+		return generic, nil
+	}
+
+	shop := p.Parse(doc, pageURL)
+
+	out := Product{
+		Title:    first(cleanTitle(shop.Title), generic.Title),
+		ImageUrl: first(resolve(pageURL, shop.ImageUrl), generic.ImageUrl),
+		Price:    shop.Price,
+	}
+	if out.Price == nil {
+		out.Price = generic.Price
+	}
+
+	return out, nil
+}
@@ -0,0 +1,141 @@
+// Package money validates currencies and adds up prices. Amounts are always
+// integers in the currency's minor units (kopecks, cents, ...).
+package money
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"wish_list/internal/entity"
+)
+
+// MaxAmount is the largest accepted amount in minor units. A price times
+// the largest item quantity stays far below the int64 range, so sums over
+// a wishlist cannot wrap around.
+const MaxAmount int64 = 1_000_000_000_000
+
+// minorUnits maps active ISO 4217 codes to the number of digits after the
+// decimal separator.
+var minorUnits = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+	"AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0,
+	"BMD": 2, "BND": 2, "BOB": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2,
+	"BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CRC": 2,
+	"CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2,
+	"ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2,
+	"GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2,
+	"HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2,
+	"JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0,
+	"KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2,
+	"LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2,
+	"MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2, "NAD": 2,
+	"NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2,
+	"PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2,
+	"RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2,
+	"SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2,
+	"SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2, "TTD": 2,
+	"TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "UYU": 2, "UZS": 2, "VES": 2,
+	"VND": 0, "VUV": 0, "WST": 2, "XAF": 0, "XCD": 2, "XOF": 0, "XPF": 0, "YER": 2,
+	"ZAR": 2, "ZMW": 2, "ZWL": 2,
+}
+
+// Normalize upper-cases code and reports whether it is a known ISO 4217
+// currency.
+func Normalize(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	_, ok := minorUnits[code]
+	return code, ok
+}
+
+// Parse converts a decimal amount as written on web pages, such as
+// "1 299,90", "1,299.90" or "1299", to minor units of a known currency.
+// Fraction digits beyond the currency's precision must be zeros and the
+// result may not exceed MaxAmount.
+func Parse(amount, currency string) (int64, bool) {
+	units, ok := minorUnits[currency]
+	if !ok {
+		return 0, false
+	}
+
+	amount = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '\'' {
+			return -1
+		}
+		return r
+	}, amount)
+
+	whole, frac := amount, ""
+	if i := strings.LastIndexAny(amount, ".,"); i >= 0 && isDecimalSeparator(amount, i, units) {
+		whole, frac = amount[:i], amount[i+1:]
+	}
+	whole = strings.NewReplacer(",", "", ".", "").Replace(whole)
+
+	if whole == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, false
+	}
+
+	if len(frac) > units {
+		if strings.Trim(frac[units:], "0") != "" {
+			return 0, false
+		}
+		frac = frac[:units]
+	}
+	frac += strings.Repeat("0", units-len(frac))
+
+	v, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || v > MaxAmount {
+		return 0, false
+	}
+
+	return v, true
+}
+
+// isDecimalSeparator reports whether the separator at i, the last one in s,
+// starts the fraction rather than grouping thousands. A lone separator
+// with one to three digits before it and exactly three after, as in
+// "1.299", is read as grouping unless the currency has three minor digits.
+func isDecimalSeparator(s string, i, units int) bool {
+	other := "."
+	if s[i] == '.' {
+		other = ","
+	}
+
+	switch {
+	case strings.Contains(s[:i], other):
+		return true
+	case strings.Count(s, s[i:i+1]) > 1:
+		return false
+	default:
+		return len(s)-i-1 != 3 || i > 3 || units == 3
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Totals sums item prices times quantities per currency. Items without a
+// price are skipped; a sum beyond the int64 range stops at math.MaxInt64.
+func Totals(items []entity.GiftList) map[string]int64 {
+	totals := make(map[string]int64)
+
+	for _, it := range items {
+		if it.Price == nil {
+			continue
+		}
+		sum := it.Price.Amount * int64(max(it.Quantity, 1))
+		if totals[it.Price.Currency] > math.MaxInt64-sum {
+			totals[it.Price.Currency] = math.MaxInt64
+			continue
+		}
+		totals[it.Price.Currency] += sum
+	}
+
+	return totals
+}
@@ -0,0 +1,63 @@
+package money_test
+
+import (
+	"math"
+	"testing"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/money"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		amount   string
+		currency string
+		want     int64
+		ok       bool
+	}{
+		{"1299", "RUB", 129900, true},
+		{"1 299,90", "RUB", 129990, true},
+		{"1 299,9", "RUB", 129990, true},
+		{"1,299.90", "USD", 129990, true},
+		{"1.299,90", "EUR", 129990, true},
+		{"1,299", "USD", 129900, true},
+		{"1.299.000", "RUB", 129900000, true},
+		{"12.5", "USD", 1250, true},
+		{"1299.000", "RUB", 129900, true},
+		{"1.250", "KWD", 1250, true},
+		{"1999", "JPY", 1999, true},
+		{"19.99", "JPY", 0, false},
+		{"12.345", "USD", 1234500, true},
+		{"12.3456", "USD", 0, false},
+		{"", "USD", 0, false},
+		{".5", "USD", 0, false},
+		{"-5", "USD", 0, false},
+		{"5 ₽", "RUB", 0, false},
+		{"10", "XYZ", 0, false},
+		{"99999999999999999999", "USD", 0, false},
+		{"10 000 000 000,00", "RUB", money.MaxAmount, true},
+		{"10 000 000 000,01", "RUB", 0, false},
+	}
+
+	for _, tc := range cases {
+		got, ok := money.Parse(tc.amount, tc.currency)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("Parse(%q, %s) = %d, %v; want %d, %v", tc.amount, tc.currency, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestTotals(t *testing.T) {
+	items := []entity.GiftList{
+		{Price: &entity.Price{Amount: 1500, Currency: "RUB"}, Quantity: 2},
+		{Price: &entity.Price{Amount: 100, Currency: "USD"}},
+		{Name: "unpriced"},
+	}
+	for i := 0; i < 10_000; i++ {
+		items = append(items, entity.GiftList{Price: &entity.Price{Amount: money.MaxAmount, Currency: "EUR"}, Quantity: 999})
+	}
+
+	got := money.Totals(items)
+	if got["RUB"] != 3000 || got["USD"] != 100 || got["EUR"] != math.MaxInt64 || len(got) != 3 {
+		t.Fatalf("Totals = %v", got)
+	}
+}
@@ -0,0 +1,330 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"wish_list/internal/lib/keyset"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrUnknownState    = errors.New("unknown or expired state")
+	ErrIssuerMismatch  = errors.New("issuer mismatch")
+	ErrInvalidIDToken  = errors.New("invalid id token")
+	ErrNonceMismatch   = errors.New("nonce mismatch")
+	ErrTokenEndpoint   = errors.New("token endpoint error")
+	ErrMissingIDToken  = errors.New("id_token is missing in token response")
+	ErrDiscoveryFailed = errors.New("discovery failed")
+)
+
+// maxResponseSize caps documents read from the provider.
+const maxResponseSize = 1 << 20
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// StateTTL bounds the time between AuthURL and Exchange.
+	StateTTL time.Duration
+	Leeway   time.Duration
+}
+
+// Identity is what the provider asserts about the logged in user.
+type Identity struct {
+	Subject string
+	Email   string
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type pending struct {
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+type idClaims struct {
+	Nonce string `json:"nonce"`
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// Client runs the authorization code flow with PKCE against a single
+// provider. Pending logins are kept in memory, so the callback must reach
+// the instance that produced the authorization URL.
+type Client struct {
+	cfg    Config
+	http   *http.Client
+	meta   discovery
+	parser *jwt.Parser
+	now    func() time.Time
+
+	mu      sync.Mutex
+	keys    *keyset.Set
+	pending map[string]pending
+}
+
+// Discover fetches the provider metadata and signing keys.
+func Discover(ctx context.Context, httpClient *http.Client, cfg Config) (*Client, error) {
+	const op = "oidc.Discover"
+
+	c := &Client{
+		cfg:     cfg,
+		http:    httpClient,
+		parser:  jwt.NewParser(jwt.WithoutClaimsValidation()),
+		now:     time.Now,
+		pending: make(map[string]pending),
+	}
+
+	wellKnown := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+
+	if err := c.getJSON(ctx, wellKnown, &c.meta); err != nil {
+		return nil, fmt.Errorf("%s: %w: %v", op, ErrDiscoveryFailed, err)
+	}
+
+	if c.meta.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("%s: %w: %q", op, ErrIssuerMismatch, c.meta.Issuer)
+	}
+	if c.meta.AuthorizationEndpoint == "" || c.meta.TokenEndpoint == "" || c.meta.JWKSURI == "" {
+		return nil, fmt.Errorf("%s: %w: incomplete provider metadata", op, ErrDiscoveryFailed)
+	}
+
+	if err := c.refreshKeys(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c, nil
+}
+
+// AuthURL starts a login and returns the provider URL to redirect to.
+func (c *Client) AuthURL() (string, error) {
+	const op = "oidc.AuthURL"
+
+	state, err := randomString()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := c.now()
+
+	c.mu.Lock()
+	for s, p := range c.pending {
+		if now.After(p.expiresAt) {
+			delete(c.pending, s)
+		}
+	}
+	c.pending[state] = pending{
+		verifier:  verifier,
+		nonce:     nonce,
+		expiresAt: now.Add(c.cfg.StateTTL),
+	}
+	c.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", c.cfg.ClientID)
+	q.Set("redirect_uri", c.cfg.RedirectURL)
+	q.Set("scope", strings.Join(append([]string{"openid"}, c.cfg.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(c.meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return c.meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange completes a login started by AuthURL and returns the verified
+// identity from the ID token.
+func (c *Client) Exchange(ctx context.Context, state, code string) (Identity, error) {
+	const op = "oidc.Exchange"
+
+	c.mu.Lock()
+	p, ok := c.pending[state]
+	delete(c.pending, state)
+	c.mu.Unlock()
+
+	if !ok || c.now().After(p.expiresAt) {
+		return Identity{}, fmt.Errorf("%s: %w", op, ErrUnknownState)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("client_id", c.cfg.ClientID)
+	form.Set("code_verifier", p.verifier)
+	if c.cfg.ClientSecret != "" {
+		form.Set("client_secret", c.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("%s: %w: status %d", op, ErrTokenEndpoint, res.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return Identity{}, fmt.Errorf("%s: %w: %v", op, ErrTokenEndpoint, err)
+	}
+	if tokens.IDToken == "" {
+		return Identity{}, fmt.Errorf("%s: %w", op, ErrMissingIDToken)
+	}
+
+	id, err := c.verify(ctx, tokens.IDToken, p.nonce)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (c *Client) verify(ctx context.Context, idToken, nonce string) (Identity, error) {
+	var claims idClaims
+
+	_, err := c.parser.ParseWithClaims(idToken, &claims, c.keyfunc)
+	if errors.Is(err, keyset.ErrUnknownKey) {
+		// the provider may have rotated its keys since discovery
+		if err := c.refreshKeys(ctx); err != nil {
+			return Identity{}, err
+		}
+		_, err = c.parser.ParseWithClaims(idToken, &claims, c.keyfunc)
+	}
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	now := c.now()
+
+	switch {
+	case !claims.VerifyIssuer(c.meta.Issuer, true):
+		return Identity{}, fmt.Errorf("%w: issuer", ErrInvalidIDToken)
+	case !claims.VerifyAudience(c.cfg.ClientID, true):
+		return Identity{}, fmt.Errorf("%w: audience", ErrInvalidIDToken)
+	case !claims.VerifyExpiresAt(now.Add(-c.cfg.Leeway), true):
+		return Identity{}, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case !claims.VerifyIssuedAt(now.Add(c.cfg.Leeway), false):
+		return Identity{}, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return Identity{}, fmt.Errorf("%w: sub is missing", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return Identity{}, ErrNonceMismatch
+	}
+
+	return Identity{
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}, nil
+}
+
+func (c *Client) keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		// only the provider's published public keys are trusted
+		return nil, fmt.Errorf("%w: %s", keyset.ErrAlgMismatch, token.Method.Alg())
+	}
+
+	c.mu.Lock()
+	keys := c.keys
+	c.mu.Unlock()
+
+	return keys.Keyfunc(token)
+}
+
+func (c *Client) refreshKeys(ctx context.Context) error {
+	var raw json.RawMessage
+
+	if err := c.getJSON(ctx, c.meta.JWKSURI, &raw); err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+
+	keys, err := keyset.FromJWKS(raw)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, res.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(v)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
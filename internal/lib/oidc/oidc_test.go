@@ -0,0 +1,239 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+	"wish_list/internal/lib/oidc"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const clientID = "wishlist"
+
+// stubProvider is a minimal OpenID provider: it publishes discovery and
+// JWKS documents and redeems codes registered through authorize.
+type stubProvider struct {
+	t   *testing.T
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]grant
+	// tamper lets a test alter the ID token claims before signing.
+	tamper func(jwt.MapClaims)
+}
+
+type grant struct {
+	challenge string
+	nonce     string
+	subject   string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &stubProvider{t: t, key: key, codes: make(map[string]grant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.srv.URL,
+			"authorization_endpoint": p.srv.URL + "/authorize",
+			"token_endpoint":         p.srv.URL + "/token",
+			"jwks_uri":               p.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "stub",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   enc.EncodeToString(key.N.Bytes()),
+				"e":   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.token)
+
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+
+	return p
+}
+
+// authorize plays the user approving the login at authURL.
+func (p *stubProvider) authorize(authURL, subject string) (state, code string) {
+	p.t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	q := u.Query()
+
+	if q.Get("client_id") != clientID || q.Get("code_challenge_method") != "S256" {
+		p.t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+
+	code = "code-" + subject
+
+	p.mu.Lock()
+	p.codes[code] = grant{
+		challenge: q.Get("code_challenge"),
+		nonce:     q.Get("nonce"),
+		subject:   subject,
+	}
+	p.mu.Unlock()
+
+	return q.Get("state"), code
+}
+
+func (p *stubProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	g, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   p.srv.URL,
+		"aud":   clientID,
+		"sub":   g.subject,
+		"nonce": g.nonce,
+		"email": g.subject + "@example.com",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+	}
+	if p.tamper != nil {
+		p.tamper(claims)
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = "stub"
+
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "opaque",
+		"token_type":   "Bearer",
+		"id_token":     signed,
+	})
+}
+
+func newClient(t *testing.T, p *stubProvider) *oidc.Client {
+	t.Helper()
+
+	c, err := oidc.Discover(context.Background(), p.srv.Client(), oidc.Config{
+		Issuer:      p.srv.URL,
+		ClientID:    clientID,
+		RedirectURL: "http://localhost/callback",
+		StateTTL:    time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+
+	return c
+}
+
+func TestLogin(t *testing.T) {
+	p := newStubProvider(t)
+	c := newClient(t, p)
+
+	authURL, err := c.AuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, code := p.authorize(authURL, "alice")
+
+	id, err := c.Exchange(context.Background(), state, code)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+
+	if id.Subject != "alice" || id.Email != "alice@example.com" {
+		t.Fatalf("unexpected identity %+v", id)
+	}
+
+	if _, err := c.Exchange(context.Background(), state, code); !errors.Is(err, oidc.ErrUnknownState) {
+		t.Fatalf("state reuse: got %v, want ErrUnknownState", err)
+	}
+}
+
+func TestRejectsInvalidIDToken(t *testing.T) {
+	cases := []struct {
+		name   string
+		tamper func(jwt.MapClaims)
+		want   error
+	}{
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "someone-else" }, oidc.ErrInvalidIDToken},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, oidc.ErrInvalidIDToken},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, oidc.ErrInvalidIDToken},
+		{"wrong nonce", func(c jwt.MapClaims) { c["nonce"] = "replayed" }, oidc.ErrNonceMismatch},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newStubProvider(t)
+			p.tamper = tc.tamper
+			c := newClient(t, p)
+
+			authURL, err := c.AuthURL()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, code := p.authorize(authURL, "mallory")
+
+			if _, err := c.Exchange(context.Background(), state, code); !errors.Is(err, tc.want) {
+				t.Fatalf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnknownState(t *testing.T) {
+	p := newStubProvider(t)
+	c := newClient(t, p)
+
+	if _, err := c.Exchange(context.Background(), "forged", "code"); !errors.Is(err, oidc.ErrUnknownState) {
+		t.Fatalf("got %v, want ErrUnknownState", err)
+	}
+}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Store persists refresh tokens and the access token denylist.
+type Store interface {
+	CreateRefreshToken(ctx context.Context, uid int, familyId, tokenHash string, expiresAt time.Time) error
+	RotateRefreshToken(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (int, error)
+	RevokeRefreshFamily(ctx context.Context, uid int, tokenHash string) error
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+}
+
+type AccessIssuer interface {
+	Issue(uid int) (string, time.Time, error)
+}
+
+type Tokens struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// Manager issues access/refresh token pairs. Refresh tokens are opaque
+// random strings; only their SHA-256 is stored. Every refresh rotates the
+// token within its family, so a replayed token can be detected.
+type Manager struct {
+	store      Store
+	issuer     AccessIssuer
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+func New(store Store, issuer AccessIssuer, refreshTTL time.Duration) *Manager {
+	return &Manager{
+		store:      store,
+		issuer:     issuer,
+		refreshTTL: refreshTTL,
+		now:        time.Now,
+	}
+}
+
+// Start opens a new refresh token family for uid.
+func (m *Manager) Start(ctx context.Context, uid int) (Tokens, error) {
+	const op = "session.Start"
+
+	familyId, err := randomString(16)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	refresh, err := randomString(32)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	refreshExp := m.now().Add(m.refreshTTL)
+
+	if err := m.store.CreateRefreshToken(ctx, uid, familyId, HashToken(refresh), refreshExp); err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return m.withAccess(uid, refresh, refreshExp)
+}
+
+// Refresh exchanges a refresh token for a new pair. Errors from the store,
+// such as storage.ErrTokenReused, are wrapped and returned as is.
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (Tokens, error) {
+	const op = "session.Refresh"
+
+	next, err := randomString(32)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	refreshExp := m.now().Add(m.refreshTTL)
+
+	uid, err := m.store.RotateRefreshToken(ctx, HashToken(refreshToken), HashToken(next), refreshExp)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return m.withAccess(uid, next, refreshExp)
+}
+
+// Logout denylists the access token jti until it expires and revokes the
+// refresh token family if a refresh token is given.
+func (m *Manager) Logout(ctx context.Context, uid int, jti string, accessExp time.Time, refreshToken string) error {
+	const op = "session.Logout"
+
+	if jti != "" {
+		if err := m.store.RevokeToken(ctx, jti, accessExp); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if refreshToken != "" {
+		if err := m.store.RevokeRefreshFamily(ctx, uid, HashToken(refreshToken)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) withAccess(uid int, refresh string, refreshExp time.Time) (Tokens, error) {
+	access, accessExp, err := m.issuer.Issue(uid)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("session: %w", err)
+	}
+
+	return Tokens{
+		AccessToken:      access,
+		AccessExpiresAt:  accessExp,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshExp,
+	}, nil
+}
+
+// HashToken returns the value stored in place of an opaque token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,117 @@
+// Package urlnorm validates item links and brings them to a canonical form,
+// so the same product page saved twice compares equal.
+package urlnorm
+
+import (
+	"net/url"
+	"strings"
+)
+
+// MaxLen is the longest link accepted, before and after normalization.
+const MaxLen = 2048
+
+// trackingParams are query parameters added by ad networks and mailing
+// tools. They never change the page served. Parameters starting with
+// "utm_" are removed as well.
+var trackingParams = map[string]bool{
+	"fbclid":    true,
+	"gclid":     true,
+	"gclsrc":    true,
+	"dclid":     true,
+	"gbraid":    true,
+	"wbraid":    true,
+	"msclkid":   true,
+	"yclid":     true,
+	"ysclid":    true,
+	"igshid":    true,
+	"mc_cid":    true,
+	"mc_eid":    true,
+	"_openstat": true,
+	"_hsenc":    true,
+	"_hsmi":     true,
+}
+
+// Normalize checks that raw is an absolute http or https URL of at most
+// MaxLen bytes and returns its canonical form: lowercase scheme and host,
+// no default port, "/" for an empty path and no tracking parameters. The
+// order and encoding of the remaining parameters are kept.
+func Normalize(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > MaxLen || strings.ContainsFunc(raw, isControl) {
+		return "", false
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Opaque != "" || u.User != nil {
+		return "", false
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return "", false
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && !defaultPort(u.Scheme, port) {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	}
+
+	u.RawQuery = stripTracking(u.RawQuery)
+	u.ForceQuery = false
+
+	s := u.String()
+	if len(s) > MaxLen {
+		return "", false
+	}
+
+	return s, true
+}
+
+// stripTracking removes tracking parameters from a raw query string,
+// leaving the others untouched.
+func stripTracking(query string) string {
+	if query == "" {
+		return ""
+	}
+
+	var kept []string
+
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		key = strings.ToLower(key)
+
+		if strings.HasPrefix(key, "utm_") || trackingParams[key] {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+
+	return strings.Join(kept, "&")
+}
+
+func defaultPort(scheme, port string) bool {
+	return scheme == "http" && port == "80" || scheme == "https" && port == "443"
+}
+
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
@@ -0,0 +1,245 @@
+// Package linkchecker periodically requests item URLs and records whether
+// they still lead to a page, so owners can fix dead links before sharing
+// a wishlist.
+package linkchecker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/logger/sl"
+)
+
+// Prober requests a URL without following redirects and returns the
+// response status.
+type Prober interface {
+	Probe(ctx context.Context, url string) (int, error)
+}
+
+// Store lists the links due for a check and records the results.
+type Store interface {
+	LinkItems(ctx context.Context, checkedBefore time.Time, limit int) ([]entity.LinkItem, error)
+	RecordLink(ctx context.Context, itemId int, url, status string, checkedAt time.Time) error
+}
+
+// Options tune a Checker. Zero values fall back to the defaults.
+type Options struct {
+	Interval     time.Duration // how often each link is re-checked; default 24h
+	Poll         time.Duration // how often due links are looked up; default 1m
+	BatchSize    int           // links checked per poll; default 100
+	Workers      int           // concurrent checks; default 8
+	HostInterval time.Duration // minimum gap between requests to one host; default 2s
+	Timeout      time.Duration // per request; default 10s
+}
+
+type Checker struct {
+	log    *slog.Logger
+	prober Prober
+	store  Store
+	hosts  *hostLimiter
+	opts   Options
+}
+
+func New(log *slog.Logger, prober Prober, store Store, opts Options) *Checker {
+	if opts.Interval <= 0 {
+		opts.Interval = 24 * time.Hour
+	}
+	if opts.Poll <= 0 {
+		opts.Poll = time.Minute
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 8
+	}
+	if opts.HostInterval <= 0 {
+		opts.HostInterval = 2 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	return &Checker{
+		log:    log.With(slog.String("component", "linkchecker")),
+		prober: prober,
+		store:  store,
+		hosts:  &hostLimiter{gap: opts.HostInterval, next: make(map[string]time.Time)},
+		opts:   opts,
+	}
+}
+
+// Run checks due links every Poll until ctx is done.
+func (c *Checker) Run(ctx context.Context) {
+	for {
+		c.Check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.opts.Poll):
+		}
+	}
+}
+
+// Check probes one batch of links not checked within Interval and returns
+// how many were recorded. At most Workers requests run at once and
+// requests to the same host are spaced by HostInterval.
+func (c *Checker) Check(ctx context.Context) int {
+	items, err := c.store.LinkItems(ctx, time.Now().Add(-c.opts.Interval), c.opts.BatchSize)
+	if err != nil {
+		c.log.Error("failed to list links", sl.Err(err))
+		return 0
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		checked int
+	)
+
+	jobs := make(chan entity.LinkItem)
+
+	for i := 0; i < c.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for it := range jobs {
+				if c.check(ctx, it) {
+					mu.Lock()
+					checked++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, it := range items {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- it:
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+	c.hosts.forget(time.Now())
+
+	return checked
+}
+
+func (c *Checker) check(ctx context.Context, it entity.LinkItem) bool {
+	log := c.log.With(slog.Int("item_id", it.GiftId))
+
+	// A URL that does not parse fails the probe and is recorded as an
+	// error like any other unreachable link.
+	var host string
+	if u, err := url.Parse(it.Url); err == nil {
+		host = u.Hostname()
+	}
+
+	if !c.hosts.wait(ctx, host) {
+		return false
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	code, err := c.prober.Probe(probeCtx, it.Url)
+	if err != nil && ctx.Err() != nil {
+		return false
+	}
+
+	status := Status(code, err)
+	if err != nil {
+		log.Debug("link is unreachable", slog.String("status", status), sl.Err(err))
+	} else if status != entity.LinkOK {
+		log.Debug("link is not ok", slog.String("status", status), slog.Int("code", code))
+	}
+
+	if err := c.store.RecordLink(ctx, it.GiftId, it.Url, status, time.Now()); err != nil {
+		log.Error("failed to record link status", sl.Err(err))
+		return false
+	}
+
+	return true
+}
+
+// Status classifies the outcome of a probe.
+func Status(code int, err error) string {
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+			return entity.LinkTimeout
+		}
+		return entity.LinkError
+	}
+
+	switch {
+	case code >= 200 && code < 300:
+		return entity.LinkOK
+	case code >= 300 && code < 400:
+		return entity.LinkRedirect
+	case code == http.StatusNotFound || code == http.StatusGone:
+		return entity.LinkNotFound
+	case code == http.StatusUnauthorized || code == http.StatusForbidden || code == http.StatusTooManyRequests:
+		return entity.LinkBlocked
+	}
+
+	return entity.LinkError
+}
+
+// hostLimiter hands out request slots per host, at least gap apart.
+type hostLimiter struct {
+	mu   sync.Mutex
+	gap  time.Duration
+	next map[string]time.Time
+}
+
+// wait blocks until a request to host may be sent. It returns false when
+// ctx is done first.
+func (l *hostLimiter) wait(ctx context.Context, host string) bool {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.gap)
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.C:
+		}
+	}
+
+	return ctx.Err() == nil
+}
+
+// forget drops hosts whose next slot has already passed, so the map does
+// not grow with every host ever checked.
+func (l *hostLimiter) forget(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for host, at := range l.next {
+		if at.Before(now) {
+			delete(l.next, host)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package linkchecker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/metadata"
+	"wish_list/internal/linkchecker"
+	"wish_list/internal/storage/memory"
+)
+
+func discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// seed creates a wishlist with one item per url and returns the item ids.
+func seed(t *testing.T, store *memory.Storage, urls ...string) (int, int, []int) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	uid, err := store.CreateUser(ctx, "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listId, err := store.CreateList(ctx, "birthday", "alias", uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for _, u := range urls {
+		id, err := store.CreateItem(ctx, listId, uid, entity.GiftCreate{Name: "gift", Url: u, Quantity: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	return listId, uid, ids
+}
+
+func TestCheckRecordsStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte("<html></html>"))
+		case "/moved":
+			http.Redirect(w, r, "/", http.StatusMovedPermanently)
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/robots-only":
+			w.WriteHeader(http.StatusForbidden)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			time.Sleep(300 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	want := map[string]string{
+		srv.URL + "/ok":          entity.LinkOK,
+		srv.URL + "/moved":       entity.LinkRedirect,
+		srv.URL + "/missing":     entity.LinkNotFound,
+		srv.URL + "/gone":        entity.LinkNotFound,
+		srv.URL + "/robots-only": entity.LinkBlocked,
+		srv.URL + "/broken":      entity.LinkError,
+		srv.URL + "/slow":        entity.LinkTimeout,
+		"http://127.0.0.1:1/":    entity.LinkError,
+	}
+
+	var urls []string
+	for u := range want {
+		urls = append(urls, u)
+	}
+
+	store := memory.New()
+	listId, uid, _ := seed(t, store, append(urls, "handmade")...)
+
+	checker := linkchecker.New(discard(), metadata.NewFetcher(metadata.Options{AllowPrivate: true}), store, linkchecker.Options{
+		HostInterval: time.Millisecond,
+		Timeout:      100 * time.Millisecond,
+	})
+
+	if n := checker.Check(context.Background()); n != len(want) {
+		t.Fatalf("checked %d links, want %d", n, len(want))
+	}
+	if n := checker.Check(context.Background()); n != 0 {
+		t.Fatalf("re-checked %d links before the interval", n)
+	}
+
+	items, err := store.GetByWishId(context.Background(), listId, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, it := range items {
+		status, ok := want[it.Url]
+		if !ok {
+			if it.Link != nil {
+				t.Errorf("%s: non-web url was checked", it.Url)
+			}
+			continue
+		}
+		if it.Link == nil || it.Link.Status != status {
+			t.Errorf("%s: link = %+v, want status %s", it.Url, it.Link, status)
+			continue
+		}
+		broken := status == entity.LinkNotFound || status == entity.LinkTimeout || status == entity.LinkError
+		if it.Link.Broken != broken || it.Link.CheckedAt.IsZero() {
+			t.Errorf("%s: link = %+v", it.Url, it.Link)
+		}
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	const (
+		workers = 2
+		gap     = 50 * time.Millisecond
+	)
+
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+		seen     = map[string][]time.Time{}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxSeen = max(maxSeen, inFlight)
+		host, _, _ := strings.Cut(r.Host, ":")
+		seen[host] = append(seen[host], time.Now())
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	other := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	var urls []string
+	for _, base := range []string{srv.URL, other} {
+		for _, p := range []string{"/a", "/b", "/c"} {
+			urls = append(urls, base+p)
+		}
+	}
+
+	store := memory.New()
+	seed(t, store, urls...)
+
+	checker := linkchecker.New(discard(), metadata.NewFetcher(metadata.Options{AllowPrivate: true}), store, linkchecker.Options{
+		Workers:      workers,
+		HostInterval: gap,
+	})
+
+	if n := checker.Check(context.Background()); n != len(urls) {
+		t.Fatalf("checked %d links, want %d", n, len(urls))
+	}
+
+	if maxSeen > workers {
+		t.Fatalf("%d requests in flight, want at most %d", maxSeen, workers)
+	}
+
+	for host, times := range seen {
+		if len(times) != 3 {
+			t.Fatalf("%s: %d requests, want 3", host, len(times))
+		}
+		for i := 1; i < len(times); i++ {
+			// Leave a little room for the time between the slot and the request
+			// reaching the server.
+			if d := times[i].Sub(times[i-1]); d < gap-5*time.Millisecond {
+				t.Fatalf("%s: requests %v apart, want at least %v", host, d, gap)
+			}
+		}
+	}
+}
+
+func TestCheckStopsOnCancel(t *testing.T) {
+	store := memory.New()
+	seed(t, store, "http://example.com/a", "http://example.com/b")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	prober := proberFunc(func(context.Context, string) (int, error) {
+		cancel()
+		return 0, errors.New("connection reset")
+	})
+
+	checker := linkchecker.New(discard(), prober, store, linkchecker.Options{Workers: 1, HostInterval: time.Hour})
+
+	if n := checker.Check(ctx); n != 0 {
+		t.Fatalf("recorded %d links after cancellation", n)
+	}
+}
+
+type proberFunc func(ctx context.Context, url string) (int, error)
+
+func (f proberFunc) Probe(ctx context.Context, url string) (int, error) { return f(ctx, url) }
@@ -0,0 +1,189 @@
+// Package pricetracker periodically re-fetches the pages of items with a
+// web URL, records the prices found and tells owners when a price drops
+// below the alert threshold they set.
+package pricetracker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/logger/sl"
+	"wish_list/internal/lib/metadata"
+)
+
+// Fetcher reads product details from a page.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (metadata.Product, error)
+}
+
+// Store lists the items due for a check and records what was found.
+type Store interface {
+	TrackedItems(ctx context.Context, checkedBefore time.Time, limit int) ([]entity.TrackedItem, error)
+	RecordPrice(ctx context.Context, itemId int, price *entity.Price, checkedAt time.Time) error
+}
+
+// Notifier delivers price drop events to item owners.
+type Notifier interface {
+	PriceDropped(ctx context.Context, drop entity.PriceDrop) error
+}
+
+// Clock abstracts time so tests can drive the scheduler.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Options tune a Tracker. Zero values fall back to the defaults.
+type Options struct {
+	Interval  time.Duration // how often each item is re-checked; default 6h
+	Poll      time.Duration // how often due items are looked up; default 1m
+	BatchSize int           // items checked per poll; default 50
+	Timeout   time.Duration // per page fetch; default 15s
+	Clock     Clock         // default the system clock
+}
+
+type Tracker struct {
+	log      *slog.Logger
+	fetcher  Fetcher
+	store    Store
+	notifier Notifier
+	opts     Options
+}
+
+func New(log *slog.Logger, fetcher Fetcher, store Store, notifier Notifier, opts Options) *Tracker {
+	if opts.Interval <= 0 {
+		opts.Interval = 6 * time.Hour
+	}
+	if opts.Poll <= 0 {
+		opts.Poll = time.Minute
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 50
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 15 * time.Second
+	}
+	if opts.Clock == nil {
+		opts.Clock = realClock{}
+	}
+
+	return &Tracker{
+		log:      log.With(slog.String("component", "pricetracker")),
+		fetcher:  fetcher,
+		store:    store,
+		notifier: notifier,
+		opts:     opts,
+	}
+}
+
+// Run checks due items every Poll until ctx is done. A check in progress
+// is abandoned on cancellation.
+func (t *Tracker) Run(ctx context.Context) {
+	for {
+		t.checkDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.opts.Clock.After(t.opts.Poll):
+		}
+	}
+}
+
+// checkDue re-fetches one batch of items not checked within Interval.
+func (t *Tracker) checkDue(ctx context.Context) {
+	now := t.opts.Clock.Now()
+
+	items, err := t.store.TrackedItems(ctx, now.Add(-t.opts.Interval), t.opts.BatchSize)
+	if err != nil {
+		t.log.Error("failed to list tracked items", sl.Err(err))
+		return
+	}
+
+	for _, it := range items {
+		if ctx.Err() != nil {
+			return
+		}
+		t.check(ctx, it)
+	}
+}
+
+func (t *Tracker) check(ctx context.Context, it entity.TrackedItem) {
+	log := t.log.With(slog.Int("item_id", it.GiftId))
+
+	fetchCtx, cancel := context.WithTimeout(ctx, t.opts.Timeout)
+	defer cancel()
+
+	p, err := t.fetcher.Fetch(fetchCtx, it.Url)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Info("failed to fetch item page", sl.Err(err))
+	}
+
+	// The item is marked as checked even when the fetch failed, so a broken
+	// page is retried after Interval rather than on every poll.
+	if err := t.store.RecordPrice(ctx, it.GiftId, p.Price, t.opts.Clock.Now()); err != nil {
+		log.Error("failed to record price", sl.Err(err))
+		return
+	}
+
+	if !dropped(it, p.Price) {
+		return
+	}
+
+	drop := entity.PriceDrop{
+		GiftId:     it.GiftId,
+		WishListId: it.WishListId,
+		UID:        it.UID,
+		Name:       it.Name,
+		Url:        it.Url,
+		Price:      *p.Price,
+		Threshold:  *it.PriceAlert,
+		Previous:   it.LastPrice,
+	}
+
+	if err := t.notifier.PriceDropped(ctx, drop); err != nil {
+		log.Error("failed to send price drop notification", sl.Err(err))
+	}
+}
+
+// dropped reports whether price has just fallen below the item's alert
+// threshold. Owners are notified once per crossing, not on every check
+// while the price stays low.
+func dropped(it entity.TrackedItem, price *entity.Price) bool {
+	alert := it.PriceAlert
+	if alert == nil || price == nil || price.Currency != alert.Currency || price.Amount >= alert.Amount {
+		return false
+	}
+
+	last := it.LastPrice
+	return last == nil || last.Currency != alert.Currency || last.Amount >= alert.Amount
+}
+
+// LogNotifier emits price drops as structured log events.
+type LogNotifier struct {
+	Log *slog.Logger
+}
+
+func (n LogNotifier) PriceDropped(_ context.Context, drop entity.PriceDrop) error {
+	n.Log.Info("price dropped",
+		slog.String("event", "price_drop"),
+		slog.Int("uid", drop.UID),
+		slog.Int("item_id", drop.GiftId),
+		slog.Int("wish_list_id", drop.WishListId),
+		slog.String("url", drop.Url),
+		slog.Int64("price", drop.Price.Amount),
+		slog.Int64("threshold", drop.Threshold.Amount),
+		slog.String("currency", drop.Price.Currency),
+	)
+
+	return nil
+}
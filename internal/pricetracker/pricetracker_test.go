@@ -0,0 +1,217 @@
+package pricetracker_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wish_list/internal/entity"
+	"wish_list/internal/lib/metadata"
+	"wish_list/internal/pricetracker"
+	"wish_list/internal/storage/memory"
+)
+
+// fakeClock only moves when Advance is called. Every After call is
+// reported on waiting, which tells the test a poll has finished.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []timer
+	waiting chan struct{}
+}
+
+type timer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		waiting: make(chan struct{}),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, timer{at: c.now.Add(d), ch: ch})
+	c.mu.Unlock()
+
+	c.waiting <- struct{}{}
+
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = pending
+}
+
+type fakeNotifier struct {
+	mu    sync.Mutex
+	drops []entity.PriceDrop
+}
+
+func (n *fakeNotifier) PriceDropped(_ context.Context, drop entity.PriceDrop) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.drops = append(n.drops, drop)
+	return nil
+}
+
+func (n *fakeNotifier) list() []entity.PriceDrop {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]entity.PriceDrop(nil), n.drops...)
+}
+
+func TestTracker(t *testing.T) {
+	var cents atomic.Int64
+	cents.Store(1000)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head>
+<meta property="og:title" content="Teapot">
+<meta property="product:price:amount" content="%d.%02d">
+<meta property="product:price:currency" content="USD">
+</head></html>`, cents.Load()/100, cents.Load()%100)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := memory.New()
+
+	uid, err := store.CreateUser(ctx, "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listId, err := store.CreateList(ctx, "birthday", "alias", uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemId, err := store.CreateItem(ctx, listId, uid, entity.GiftCreate{Name: "teapot", Url: srv.URL + "/teapot", Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateItem(ctx, listId, uid, entity.GiftCreate{Name: "card", Url: "handmade", Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.UpdateItem(ctx, itemId, uid, entity.GiftUpdate{PriceAlert: &entity.Price{Amount: 900, Currency: "USD"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := newFakeClock()
+	notifier := &fakeNotifier{}
+	tracker := pricetracker.New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		metadata.NewFetcher(metadata.Options{AllowPrivate: true}),
+		store,
+		notifier,
+		pricetracker.Options{Interval: time.Hour, Poll: time.Minute, Clock: clock},
+	)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		tracker.Run(runCtx)
+		close(done)
+	}()
+
+	// poll advances the clock by d and waits for the poll it triggers.
+	poll := func(d time.Duration) {
+		t.Helper()
+		clock.Advance(d)
+		select {
+		case <-clock.waiting:
+		case <-time.After(5 * time.Second):
+			t.Fatal("tracker did not poll")
+		}
+	}
+	history := func() []int64 {
+		t.Helper()
+		points, err := store.PriceHistory(ctx, itemId, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var amounts []int64
+		for _, p := range points {
+			amounts = append(amounts, p.Amount)
+		}
+		return amounts
+	}
+
+	poll(0)
+	if got := history(); len(got) != 1 || got[0] != 1000 {
+		t.Fatalf("history after first poll = %v, want [1000]", got)
+	}
+
+	poll(time.Minute)
+	if got := history(); len(got) != 1 {
+		t.Fatalf("item re-checked before the interval: history %v", got)
+	}
+
+	cents.Store(850)
+	poll(time.Hour + time.Minute)
+	if got := history(); len(got) != 2 || got[1] != 850 {
+		t.Fatalf("history after drop = %v, want [1000 850]", got)
+	}
+	drops := notifier.list()
+	if len(drops) != 1 {
+		t.Fatalf("%d notifications, want 1", len(drops))
+	}
+	if d := drops[0]; d.GiftId != itemId || d.UID != uid || d.Price.Amount != 850 ||
+		d.Threshold.Amount != 900 || d.Previous == nil || d.Previous.Amount != 1000 {
+		t.Fatalf("notification = %+v", d)
+	}
+
+	cents.Store(700)
+	poll(time.Hour + time.Minute)
+	if got := history(); len(got) != 3 {
+		t.Fatalf("history = %v, want three points", got)
+	}
+	if n := len(notifier.list()); n != 1 {
+		t.Fatalf("%d notifications while the price stays low, want 1", n)
+	}
+
+	cents.Store(950)
+	poll(time.Hour + time.Minute)
+	cents.Store(800)
+	poll(time.Hour + time.Minute)
+	if n := len(notifier.list()); n != 2 {
+		t.Fatalf("%d notifications after the price crossed again, want 2", n)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tracker did not stop on cancellation")
+	}
+}
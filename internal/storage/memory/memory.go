@@ -0,0 +1,1288 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wish_list/internal/entity"
+	"wish_list/internal/storage"
+)
+
+type wishList struct {
+	id          int
+	name        string
+	uid         int
+	alias       string
+	description string
+	eventDate   string
+	coverEmoji  string
+	coverColor  string
+	surprise    bool
+}
+
+func (l *wishList) entity() entity.WishList {
+	return entity.WishList{
+		WishListId:  strconv.Itoa(l.id),
+		Name:        l.name,
+		UID:         l.uid,
+		Alias:       l.alias,
+		Description: l.description,
+		EventDate:   l.eventDate,
+		CoverEmoji:  l.coverEmoji,
+		CoverColor:  l.coverColor,
+		Surprise:    l.surprise,
+	}
+}
+
+type item struct {
+	id          int
+	wishListId  int
+	name        string
+	url         string
+	clicks      int
+	description string
+	imageUrl    string
+	options     entity.Options
+	price       *entity.Price
+	priceAlert  *entity.Price
+	checkedAt   time.Time
+	history     []entity.PricePoint
+	link        *entity.LinkHealth
+	quantity    int
+	priority    string
+	position    int
+	funded      bool
+}
+
+type reservation struct {
+	entity.Reservation
+	id     int
+	itemId int
+}
+
+type contribution struct {
+	entity.Contribution
+	itemId int
+}
+
+type user struct {
+	uid          int
+	login        string
+	passwordHash string
+}
+
+// identity is a user of an external provider.
+type identity struct {
+	provider string
+	subject  string
+}
+
+type refreshToken struct {
+	uid       int
+	familyId  string
+	expiresAt time.Time
+	used      bool
+	revoked   bool
+}
+
+type apiToken struct {
+	entity.APIToken
+	uid       int
+	tokenHash string
+	revoked   bool
+}
+
+// Storage keeps everything in process memory. It mirrors the behaviour of
+// the postgres backend and is meant for tests and local demos. Operations
+// never block on I/O, so contexts are accepted only to satisfy storage.Store.
+type Storage struct {
+	mu sync.Mutex
+
+	lastId        int
+	lists         map[int]*wishList
+	items         map[int]*item
+	reservations  map[int]*reservation
+	contributions map[int]*contribution
+	users         map[int]*user
+	identities    map[identity]int
+	refresh       map[string]*refreshToken
+	revoked       map[string]time.Time
+	apiTokens     map[int]*apiToken
+}
+
+func New() *Storage {
+	return &Storage{
+		lists:         make(map[int]*wishList),
+		items:         make(map[int]*item),
+		reservations:  make(map[int]*reservation),
+		contributions: make(map[int]*contribution),
+		users:         make(map[int]*user),
+		identities:    make(map[identity]int),
+		refresh:       make(map[string]*refreshToken),
+		revoked:       make(map[string]time.Time),
+		apiTokens:     make(map[int]*apiToken),
+	}
+}
+
+// nextId hands out ids from a single sequence. Callers hold s.mu.
+func (s *Storage) nextId() int {
+	s.lastId++
+	return s.lastId
+}
+
+func (s *Storage) CreateList(ctx context.Context, name, alias string, uid int) (int, error) {
+	const op = "storage.memory.CreateList"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, l := range s.lists {
+		if l.alias == alias {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrListExists)
+		}
+	}
+
+	id := s.nextId()
+	s.lists[id] = &wishList{id: id, name: name, uid: uid, alias: alias, surprise: true}
+
+	return id, nil
+}
+
+func (s *Storage) CreateItem(ctx context.Context, wishlistId, uid int, gift entity.GiftCreate) (int, error) {
+	const op = "storage.memory.CreateItem"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListOwner(wishlistId, uid); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.checkUrlFree(wishlistId, 0, gift.Url); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	position := s.lastPosition(wishlistId) + 1
+
+	id := s.nextId()
+	s.items[id] = &item{
+		id:          id,
+		wishListId:  wishlistId,
+		name:        gift.Name,
+		url:         gift.Url,
+		description: gift.Description,
+		imageUrl:    gift.ImageUrl,
+		options:     copyOptions(gift.Options),
+		price:       copyPrice(gift.Price),
+		quantity:    gift.Quantity,
+		priority:    gift.Priority,
+		position:    position,
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetList(ctx context.Context, alias string) (entity.WishList, []entity.GiftList, error) {
+	const op = "storage.memory.GetList"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, l := range s.lists {
+		if l.alias != alias {
+			continue
+		}
+
+		var list []entity.GiftList
+
+		for _, it := range s.sortedItems(l.id) {
+			list = append(list, s.gift(it, false))
+		}
+
+		return l.entity(), list, nil
+	}
+
+	return entity.WishList{}, nil, fmt.Errorf("%s: %w", op, storage.ErrListNotFound)
+}
+
+func (s *Storage) GetAllLists(ctx context.Context, uid int) ([]entity.WishList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []entity.WishList
+
+	for _, l := range s.lists {
+		if l.uid == uid {
+			list = append(list, l.entity())
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		a, _ := strconv.Atoi(list[i].WishListId)
+		b, _ := strconv.Atoi(list[j].WishListId)
+		return a < b
+	})
+
+	return list, nil
+}
+
+func (s *Storage) UpdateList(ctx context.Context, wishListId, uid int, upd entity.WishListUpdate) (entity.WishList, error) {
+	const op = "storage.memory.UpdateList"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListOwner(wishListId, uid); err != nil {
+		return entity.WishList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	l := s.lists[wishListId]
+
+	if upd.Name != nil {
+		l.name = *upd.Name
+	}
+	if upd.Description != nil {
+		l.description = *upd.Description
+	}
+	if upd.EventDate != nil {
+		l.eventDate = *upd.EventDate
+	}
+	if upd.CoverEmoji != nil {
+		l.coverEmoji = *upd.CoverEmoji
+	}
+	if upd.CoverColor != nil {
+		l.coverColor = *upd.CoverColor
+	}
+	if upd.Surprise != nil {
+		l.surprise = *upd.Surprise
+	}
+
+	return l.entity(), nil
+}
+
+func (s *Storage) WishListDel(ctx context.Context, wishListId, uid int) error {
+	const op = "storage.memory.WishListDel"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListOwner(wishListId, uid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for id, it := range s.items {
+		if it.wishListId == wishListId {
+			s.deleteItem(id)
+		}
+	}
+	delete(s.lists, wishListId)
+
+	return nil
+}
+
+func (s *Storage) GetByWishId(ctx context.Context, wishListId, uid int) ([]entity.GiftList, error) {
+	const op = "storage.memory.GetByWishId"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListOwner(wishListId, uid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var list []entity.GiftList
+
+	for _, it := range s.sortedItems(wishListId) {
+		list = append(list, s.gift(it, true))
+	}
+
+	return list, nil
+}
+
+func (s *Storage) DelItemById(ctx context.Context, itemId, uid int) error {
+	const op = "storage.memory.DelItemById"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkItemOwner(itemId, uid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.deleteItem(itemId)
+
+	return nil
+}
+
+func (s *Storage) UpdateItem(ctx context.Context, itemId, uid int, upd entity.GiftUpdate) (entity.GiftList, error) {
+	const op = "storage.memory.UpdateItem"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkItemOwner(itemId, uid); err != nil {
+		return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	it := s.items[itemId]
+
+	if upd.Url != nil {
+		if err := s.checkUrlFree(it.wishListId, itemId, *upd.Url); err != nil {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if upd.Price != nil || upd.ClearPrice {
+		same := upd.Price != nil && it.price != nil && *it.price == *upd.Price
+		if len(s.sortedContributions(itemId)) > 0 && !same {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, s.claimConflict(it, storage.ErrItemPledged))
+		}
+	}
+
+	if upd.Quantity != nil {
+		var reserved int
+		for _, res := range s.sortedReservations(itemId) {
+			reserved += res.Quantity
+		}
+		if *upd.Quantity < reserved {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, s.claimConflict(it, storage.ErrQuantityBelow))
+		}
+		it.quantity = *upd.Quantity
+	}
+
+	if upd.Name != nil {
+		it.name = *upd.Name
+	}
+	if upd.Url != nil {
+		it.url = *upd.Url
+		it.link = nil
+	}
+	if upd.Description != nil {
+		it.description = *upd.Description
+	}
+	if upd.ImageUrl != nil {
+		it.imageUrl = *upd.ImageUrl
+	}
+	if upd.Options != nil {
+		it.options = copyOptions(*upd.Options)
+	}
+	if upd.Price != nil {
+		it.price = copyPrice(upd.Price)
+	} else if upd.ClearPrice {
+		it.price = nil
+	}
+	if upd.PriceAlert != nil {
+		it.priceAlert = copyPrice(upd.PriceAlert)
+	} else if upd.ClearAlert {
+		it.priceAlert = nil
+	}
+	if upd.Priority != nil {
+		it.priority = *upd.Priority
+	}
+
+	return s.gift(it, true), nil
+}
+
+func (s *Storage) ReorderItems(ctx context.Context, wishListId, uid int, giftIds []int) error {
+	const op = "storage.memory.ReorderItems"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListOwner(wishListId, uid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(giftIds) != len(s.sortedItems(wishListId)) {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrderMismatch)
+	}
+
+	seen := make(map[int]bool, len(giftIds))
+	for _, id := range giftIds {
+		it, ok := s.items[id]
+		if !ok || it.wishListId != wishListId || seen[id] {
+			return fmt.Errorf("%s: %w", op, storage.ErrOrderMismatch)
+		}
+		seen[id] = true
+	}
+
+	for i, id := range giftIds {
+		s.items[id].position = i + 1
+	}
+
+	return nil
+}
+
+func (s *Storage) MoveItems(ctx context.Context, wishListId, uid int, giftIds []int) error {
+	const op = "storage.memory.MoveItems"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListOwner(wishListId, uid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.checkOwnItems(uid, giftIds); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		moving []*item
+		ids    []int
+		urls   []string
+	)
+
+	for _, id := range giftIds {
+		if it := s.items[id]; it.wishListId != wishListId {
+			moving = append(moving, it)
+			ids = append(ids, id)
+			urls = append(urls, it.url)
+		}
+	}
+
+	if err := s.checkUrlsFree(wishListId, ids, urls); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	position := s.lastPosition(wishListId)
+	for _, it := range moving {
+		position++
+		it.wishListId = wishListId
+		it.position = position
+	}
+
+	return nil
+}
+
+func (s *Storage) CopyItems(ctx context.Context, wishListId, uid int, giftIds []int) ([]int, error) {
+	const op = "storage.memory.CopyItems"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkListOwner(wishListId, uid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.checkOwnItems(uid, giftIds); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sources := make([]*item, 0, len(giftIds))
+	urls := make([]string, 0, len(giftIds))
+
+	for _, id := range giftIds {
+		sources = append(sources, s.items[id])
+		urls = append(urls, s.items[id].url)
+	}
+
+	if err := s.checkUrlsFree(wishListId, nil, urls); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	position := s.lastPosition(wishListId)
+	ids := make([]int, 0, len(sources))
+
+	for _, src := range sources {
+		position++
+		id := s.nextId()
+		s.items[id] = &item{
+			id:          id,
+			wishListId:  wishListId,
+			name:        src.name,
+			url:         src.url,
+			description: src.description,
+			imageUrl:    src.imageUrl,
+			options:     copyOptions(src.options),
+			price:       copyPrice(src.price),
+			priceAlert:  copyPrice(src.priceAlert),
+			quantity:    src.quantity,
+			priority:    src.priority,
+			position:    position,
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (s *Storage) FillItem(ctx context.Context, itemId int, url string, meta entity.GiftMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[itemId]
+	if !ok || it.url != url {
+		return nil
+	}
+
+	if it.name == "" {
+		it.name = meta.Name
+	}
+	if it.imageUrl == "" {
+		it.imageUrl = meta.ImageUrl
+	}
+	if it.price == nil {
+		it.price = copyPrice(meta.Price)
+	}
+
+	return nil
+}
+
+func (s *Storage) CountClick(ctx context.Context, itemId int) (string, error) {
+	const op = "storage.memory.CountClick"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[itemId]
+	if !ok || s.lists[it.wishListId].alias == "" {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrItemNotFound)
+	}
+
+	it.clicks++
+
+	return it.url, nil
+}
+
+func (s *Storage) TrackedItems(ctx context.Context, checkedBefore time.Time, limit int) ([]entity.TrackedItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*item
+
+	for _, it := range s.items {
+		web := strings.HasPrefix(it.url, "http://") || strings.HasPrefix(it.url, "https://")
+		if web && it.checkedAt.Before(checkedBefore) {
+			due = append(due, it)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].checkedAt.Equal(due[j].checkedAt) {
+			return due[i].checkedAt.Before(due[j].checkedAt)
+		}
+		return due[i].id < due[j].id
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	var list []entity.TrackedItem
+
+	for _, it := range due {
+		t := entity.TrackedItem{
+			GiftId:     it.id,
+			WishListId: it.wishListId,
+			UID:        s.lists[it.wishListId].uid,
+			Name:       it.name,
+			Url:        it.url,
+			PriceAlert: copyPrice(it.priceAlert),
+		}
+		if n := len(it.history); n > 0 {
+			t.LastPrice = copyPrice(&it.history[n-1].Price)
+		}
+		list = append(list, t)
+	}
+
+	return list, nil
+}
+
+func (s *Storage) RecordPrice(ctx context.Context, itemId int, price *entity.Price, checkedAt time.Time) error {
+	const op = "storage.memory.RecordPrice"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[itemId]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrItemNotFound)
+	}
+
+	it.checkedAt = checkedAt
+	if price != nil {
+		it.history = append(it.history, entity.PricePoint{Price: *price, CheckedAt: checkedAt})
+	}
+
+	return nil
+}
+
+func (s *Storage) PriceHistory(ctx context.Context, itemId, uid int) ([]entity.PricePoint, error) {
+	const op = "storage.memory.PriceHistory"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkItemOwner(itemId, uid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return append([]entity.PricePoint(nil), s.items[itemId].history...), nil
+}
+
+func (s *Storage) LinkItems(ctx context.Context, checkedBefore time.Time, limit int) ([]entity.LinkItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*item
+
+	for _, it := range s.items {
+		web := strings.HasPrefix(it.url, "http://") || strings.HasPrefix(it.url, "https://")
+		if web && (it.link == nil || it.link.CheckedAt.Before(checkedBefore)) {
+			due = append(due, it)
+		}
+	}
+
+	checkedAt := func(it *item) time.Time {
+		if it.link == nil {
+			return time.Time{}
+		}
+		return it.link.CheckedAt
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if a, b := checkedAt(due[i]), checkedAt(due[j]); !a.Equal(b) {
+			return a.Before(b)
+		}
+		return due[i].id < due[j].id
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	var list []entity.LinkItem
+
+	for _, it := range due {
+		list = append(list, entity.LinkItem{GiftId: it.id, Url: it.url})
+	}
+
+	return list, nil
+}
+
+func (s *Storage) RecordLink(ctx context.Context, itemId int, url, status string, checkedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	it, ok := s.items[itemId]
+	if !ok || it.url != url {
+		return nil
+	}
+
+	it.link = entity.NewLinkHealth(status, checkedAt)
+
+	return nil
+}
+
+func (s *Storage) ReserveItem(ctx context.Context, alias string, itemId int, r entity.Reserver, quantity int) error {
+	const op = "storage.memory.ReserveItem"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.sharedItemList(alias, itemId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if r.UID != 0 && r.UID == l.uid {
+		return fmt.Errorf("%s: %w", op, storage.ErrForbidden)
+	}
+
+	if len(s.sortedContributions(itemId)) > 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrItemFunded)
+	}
+
+	var (
+		reserved int
+		own      *reservation
+	)
+	for _, res := range s.sortedReservations(itemId) {
+		reserved += res.Quantity
+		if res.HeldBy(r) {
+			own = res
+		}
+	}
+
+	if reserved+quantity > s.items[itemId].quantity {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotEnoughLeft)
+	}
+
+	if own != nil {
+		own.Quantity += quantity
+		own.Name = r.Name
+		return nil
+	}
+
+	res := &reservation{
+		Reservation: entity.Reservation{Quantity: quantity, Name: r.Name, CreatedAt: time.Now()},
+		id:          s.nextId(),
+		itemId:      itemId,
+	}
+	if r.UID != 0 {
+		res.ReserverUID = r.UID
+	} else {
+		res.GuestId = r.GuestId
+	}
+	s.reservations[res.id] = res
+
+	return nil
+}
+
+func (s *Storage) UnreserveItem(ctx context.Context, alias string, itemId int, r entity.Reserver) error {
+	const op = "storage.memory.UnreserveItem"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.sharedItemList(alias, itemId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, res := range s.sortedReservations(itemId) {
+		if res.HeldBy(r) {
+			delete(s.reservations, res.id)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: %w", op, storage.ErrNotReserved)
+}
+
+func (s *Storage) Pledge(ctx context.Context, alias string, itemId int, r entity.Reserver, amount int64) (int, error) {
+	const op = "storage.memory.Pledge"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.sharedItemList(alias, itemId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if r.UID != 0 && r.UID == l.uid {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrForbidden)
+	}
+
+	it := s.items[itemId]
+	if it.price == nil {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrNoPrice)
+	}
+	if len(s.sortedReservations(itemId)) > 0 {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrItemReserved)
+	}
+
+	var pledged int64
+	for _, c := range s.sortedContributions(itemId) {
+		pledged += c.Amount
+	}
+	if amount > it.price.Amount-pledged {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrOverfunded)
+	}
+
+	id := s.nextId()
+
+	c := &contribution{
+		Contribution: entity.Contribution{
+			ContributionId: id,
+			Amount:         amount,
+			Name:           r.Name,
+			CreatedAt:      time.Now(),
+		},
+		itemId: itemId,
+	}
+	if r.UID != 0 {
+		c.ContributorUID = r.UID
+	} else {
+		c.GuestId = r.GuestId
+	}
+	s.contributions[id] = c
+
+	if amount == it.price.Amount-pledged {
+		it.funded = true
+	}
+
+	return id, nil
+}
+
+func (s *Storage) Withdraw(ctx context.Context, alias string, itemId, contributionId int, r entity.Reserver) error {
+	const op = "storage.memory.Withdraw"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.sharedItemList(alias, itemId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	c, ok := s.contributions[contributionId]
+	if !ok || c.itemId != itemId {
+		return fmt.Errorf("%s: %w", op, storage.ErrContributionNotFound)
+	}
+	if !c.MadeBy(r) {
+		return fmt.Errorf("%s: %w", op, storage.ErrForbidden)
+	}
+
+	delete(s.contributions, contributionId)
+	s.items[itemId].funded = false
+
+	return nil
+}
+
+func (s *Storage) CreateUser(ctx context.Context, login, passwordHash string) (int, error) {
+	const op = "storage.memory.CreateUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uid, err := s.createUser(login, passwordHash)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return uid, nil
+}
+
+func (s *Storage) GetUserByLogin(ctx context.Context, login string) (entity.User, error) {
+	const op = "storage.memory.GetUserByLogin"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.login != "" && u.login == login {
+			return entity.User{UID: u.uid, Login: u.login, PasswordHash: u.passwordHash}, nil
+		}
+	}
+
+	return entity.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+}
+
+func (s *Storage) GetOrCreateExternalUser(ctx context.Context, provider, subject, email string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := identity{provider: provider, subject: subject}
+
+	if uid, ok := s.identities[key]; ok {
+		return uid, nil
+	}
+
+	// external users have no login and no password
+	uid := s.nextId()
+	s.users[uid] = &user{uid: uid}
+	s.identities[key] = uid
+
+	return uid, nil
+}
+
+func (s *Storage) CreateRefreshToken(ctx context.Context, uid int, familyId, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refresh[tokenHash] = &refreshToken{uid: uid, familyId: familyId, expiresAt: expiresAt}
+
+	return nil
+}
+
+func (s *Storage) RotateRefreshToken(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (int, error) {
+	const op = "storage.memory.RotateRefreshToken"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refresh[tokenHash]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+	}
+
+	if t.used || t.revoked {
+		s.revokeFamily(t.familyId)
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrTokenReused)
+	}
+
+	if time.Now().After(t.expiresAt) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrTokenExpired)
+	}
+
+	t.used = true
+	s.refresh[newHash] = &refreshToken{uid: t.uid, familyId: t.familyId, expiresAt: expiresAt}
+
+	return t.uid, nil
+}
+
+func (s *Storage) RevokeRefreshFamily(ctx context.Context, uid int, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.refresh[tokenHash]; ok && t.uid == uid {
+		s.revokeFamily(t.familyId)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.revoked[jti]; !ok {
+		s.revoked[jti] = expiresAt
+	}
+
+	return nil
+}
+
+func (s *Storage) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[jti]
+
+	return ok, nil
+}
+
+func (s *Storage) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	now := time.Now()
+
+	for jti, exp := range s.revoked {
+		if exp.Before(now) {
+			delete(s.revoked, jti)
+			n++
+		}
+	}
+	for hash, t := range s.refresh {
+		if t.expiresAt.Before(now) {
+			delete(s.refresh, hash)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (s *Storage) CreateAPIToken(ctx context.Context, uid int, name, tokenHash string, scopes []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextId()
+	s.apiTokens[id] = &apiToken{
+		APIToken: entity.APIToken{
+			TokenId:   id,
+			Name:      name,
+			Scopes:    slices.Clone(scopes),
+			CreatedAt: time.Now(),
+		},
+		uid:       uid,
+		tokenHash: tokenHash,
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetAPITokens(ctx context.Context, uid int) ([]entity.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []entity.APIToken
+
+	for _, t := range s.apiTokens {
+		if t.uid == uid && !t.revoked {
+			list = append(list, t.APIToken)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].TokenId < list[j].TokenId })
+
+	return list, nil
+}
+
+func (s *Storage) RevokeAPIToken(ctx context.Context, tokenId, uid int) error {
+	const op = "storage.memory.RevokeAPIToken"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.apiTokens[tokenId]
+	if !ok || t.revoked || t.uid != uid {
+		return fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+	}
+
+	t.revoked = true
+
+	return nil
+}
+
+func (s *Storage) UseAPIToken(ctx context.Context, tokenHash string) (entity.APIToken, int, error) {
+	const op = "storage.memory.UseAPIToken"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.apiTokens {
+		if t.tokenHash == tokenHash && !t.revoked {
+			now := time.Now()
+			t.LastUsedAt = &now
+			return t.APIToken, t.uid, nil
+		}
+	}
+
+	return entity.APIToken{}, 0, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+}
+
+func (s *Storage) createUser(login, passwordHash string) (int, error) {
+	for _, u := range s.users {
+		if u.login == login {
+			return 0, storage.ErrUserExists
+		}
+	}
+
+	uid := s.nextId()
+	s.users[uid] = &user{uid: uid, login: login, passwordHash: passwordHash}
+
+	return uid, nil
+}
+
+func (s *Storage) revokeFamily(familyId string) {
+	for _, t := range s.refresh {
+		if t.familyId == familyId {
+			t.revoked = true
+		}
+	}
+}
+
+func (s *Storage) sortedItems(wishListId int) []*item {
+	var items []*item
+
+	for _, it := range s.items {
+		if it.wishListId == wishListId {
+			items = append(items, it)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].position != items[j].position {
+			return items[i].position < items[j].position
+		}
+		return items[i].id < items[j].id
+	})
+
+	return items
+}
+
+// gift converts it to its API form. In the owner's view reservations are
+// dropped while the wishlist is in surprise mode. Callers hold s.mu.
+func (s *Storage) gift(it *item, ownerView bool) entity.GiftList {
+	l := s.lists[it.wishListId]
+
+	g := entity.GiftList{
+		GiftId:       it.id,
+		WishListId:   it.wishListId,
+		WishListName: l.name,
+		Name:         it.name,
+		Url:          it.url,
+		Clicks:       it.clicks,
+		Description:  it.description,
+		ImageUrl:     it.imageUrl,
+		Options:      copyOptions(it.options),
+		Price:        copyPrice(it.price),
+		PriceAlert:   copyPrice(it.priceAlert),
+		Quantity:     it.quantity,
+		Priority:     it.priority,
+		Position:     it.position,
+	}
+
+	if it.link != nil {
+		link := *it.link
+		g.Link = &link
+	}
+
+	if ownerView && l.surprise {
+		return g
+	}
+
+	remaining := it.quantity
+	for _, res := range s.sortedReservations(it.id) {
+		g.Reservations = append(g.Reservations, res.Reservation)
+		remaining -= res.Quantity
+	}
+	g.Remaining = &remaining
+
+	if it.price != nil {
+		for _, c := range s.sortedContributions(it.id) {
+			if g.Funding == nil {
+				g.Funding = &entity.Funding{Target: it.price.Amount, Currency: it.price.Currency, FullyFunded: it.funded}
+			}
+			g.Funding.Pledged += c.Amount
+			g.Funding.Contributions = append(g.Funding.Contributions, c.Contribution)
+		}
+	}
+
+	return g
+}
+
+func (s *Storage) sortedReservations(itemId int) []*reservation {
+	var list []*reservation
+
+	for _, res := range s.reservations {
+		if res.itemId == itemId {
+			list = append(list, res)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].id < list[j].id })
+
+	return list
+}
+
+func (s *Storage) sortedContributions(itemId int) []*contribution {
+	var list []*contribution
+
+	for _, c := range s.contributions {
+		if c.itemId == itemId {
+			list = append(list, c)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ContributionId < list[j].ContributionId })
+
+	return list
+}
+
+// deleteItem removes an item with its reservations and contributions.
+func (s *Storage) deleteItem(itemId int) {
+	delete(s.items, itemId)
+
+	for id, res := range s.reservations {
+		if res.itemId == itemId {
+			delete(s.reservations, id)
+		}
+	}
+
+	for id, c := range s.contributions {
+		if c.itemId == itemId {
+			delete(s.contributions, id)
+		}
+	}
+}
+
+func copyPrice(p *entity.Price) *entity.Price {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// copyOptions returns a copy of o; nil becomes an empty set, as stored by
+// the postgres backend.
+func copyOptions(o entity.Options) entity.Options {
+	c := make(entity.Options, len(o))
+	for k, v := range o {
+		c[k] = v
+	}
+	return c
+}
+
+// sharedItemList returns the wishlist of an item published under alias.
+func (s *Storage) sharedItemList(alias string, itemId int) (*wishList, error) {
+	it, ok := s.items[itemId]
+	if !ok {
+		return nil, storage.ErrItemNotFound
+	}
+
+	l := s.lists[it.wishListId]
+	if l.alias != alias {
+		return nil, storage.ErrItemNotFound
+	}
+
+	return l, nil
+}
+
+// claimConflict returns err, or storage.ErrHiddenClaims when the item's
+// wishlist is in surprise mode. Callers hold s.mu.
+func (s *Storage) claimConflict(it *item, err error) error {
+	if s.lists[it.wishListId].surprise {
+		return storage.ErrHiddenClaims
+	}
+	return err
+}
+
+func (s *Storage) checkListOwner(wishListId, uid int) error {
+	l, ok := s.lists[wishListId]
+	if !ok {
+		return storage.ErrListNotFound
+	}
+	if l.uid != uid {
+		return storage.ErrForbidden
+	}
+	return nil
+}
+
+func (s *Storage) checkItemOwner(itemId, uid int) error {
+	it, ok := s.items[itemId]
+	if !ok {
+		return storage.ErrItemNotFound
+	}
+	return s.checkListOwner(it.wishListId, uid)
+}
+
+// checkUrlFree returns storage.ErrItemExists when an item of the wishlist
+// other than exceptId already links to url.
+func (s *Storage) checkUrlFree(wishListId, exceptId int, url string) error {
+	return s.checkUrlsFree(wishListId, []int{exceptId}, []string{url})
+}
+
+// checkUrlsFree is checkUrlFree for several items joining the wishlist at
+// once; their urls must also differ from each other.
+func (s *Storage) checkUrlsFree(wishListId int, exceptIds []int, urls []string) error {
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		if seen[u] {
+			return storage.ErrItemExists
+		}
+		seen[u] = true
+	}
+
+	for _, it := range s.items {
+		if it.wishListId == wishListId && seen[it.url] && !slices.Contains(exceptIds, it.id) {
+			return storage.ErrItemExists
+		}
+	}
+	return nil
+}
+
+// checkOwnItems returns storage.ErrItemNotFound if any of the items does
+// not exist and storage.ErrForbidden if any belongs to another user.
+func (s *Storage) checkOwnItems(uid int, giftIds []int) error {
+	for _, id := range giftIds {
+		if _, ok := s.items[id]; !ok {
+			return storage.ErrItemNotFound
+		}
+	}
+	for _, id := range giftIds {
+		if err := s.checkItemOwner(id, uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) lastPosition(wishListId int) int {
+	if items := s.sortedItems(wishListId); len(items) > 0 {
+		return items[len(items)-1].position
+	}
+	return 0
+}
+
+var _ storage.Store = (*Storage)(nil)
@@ -1,20 +1,46 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pressly/goose/v3"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"time"
 	"wish_list/internal/entity"
+	"wish_list/internal/storage"
 )
 
 type Storage struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
-func New(host, port, user, password, dbName string) (*Storage, error) {
+// withTimeout derives the context a single storage call runs with.
+func (s *Storage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// uniqueViolation is the postgres error code for unique constraint violations.
+const uniqueViolation = "23505"
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// New connects to postgres and applies migrations. Every storage call is
+// bounded by queryTimeout in addition to the caller's context; zero means
+// no extra deadline.
+func New(host, port, user, password, dbName string, queryTimeout time.Duration) (*Storage, error) {
 	const op = "storage.postgres.New"
 
 	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s "+
@@ -31,7 +57,7 @@ func New(host, port, user, password, dbName string) (*Storage, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	storage := &Storage{db: db}
+	storage := &Storage{db: db, queryTimeout: queryTimeout}
 
 	cwd, _ := os.Getwd()
 	log.Println("Current working directory:", cwd)
@@ -44,16 +70,19 @@ func New(host, port, user, password, dbName string) (*Storage, error) {
 	return storage, nil
 }
 
-func (s *Storage) CreateList(name, alias string, uid int) (int, error) {
+func (s *Storage) CreateList(ctx context.Context, name, alias string, uid int) (int, error) {
 	const op = "storage.postgres.CreateList"
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	var id int
 
 	query := `
 		INSERT INTO wishlist (name, uid, alias) VALUES ($1, $2, $3) RETURNING wishlist_id;
 		`
 
-	err := s.db.QueryRow(query, name, uid, alias).Scan(&id)
+	err := s.db.QueryRowContext(ctx, query, name, uid, alias).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
@@ -61,74 +90,113 @@ func (s *Storage) CreateList(name, alias string, uid int) (int, error) {
 	return id, nil
 }
 
-func (s *Storage) CreateItem(wishlistId int, giftName, url string) (int, error) {
+func (s *Storage) CreateItem(ctx context.Context, wishlistId, uid int, gift entity.GiftCreate) (int, error) {
 	const op = "storage.postgres.CreateItem"
 
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = checkListOwner(ctx, tx, wishlistId, uid); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = checkUrlFree(ctx, tx, wishlistId, 0, gift.Url); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	var id int
 
+	var price, currency any
+	if gift.Price != nil {
+		price, currency = gift.Price.Amount, gift.Price.Currency
+	}
+
+	options, err := optionsJSON(gift.Options)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	query := `
-		INSERT INTO items (wishlist_id, name, url) VALUES ($1, $2, $3) RETURNING gift_id;
+		INSERT INTO items (wishlist_id, name, url, description, image_url, options, price, currency, quantity, priority, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
+			(SELECT COALESCE(MAX(position), 0) + 1 FROM items WHERE wishlist_id = $1))
+		RETURNING gift_id;
 		`
 
-	err := s.db.QueryRow(query, wishlistId, giftName, url).Scan(&id)
+	err = tx.QueryRowContext(ctx, query, wishlistId, gift.Name, gift.Url, gift.Description, gift.ImageUrl, options,
+		price, currency, gift.Quantity, gift.Priority).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return id, nil
 }
 
-func (s *Storage) GetList(alias string) ([]entity.GiftList, error) {
+// GetList returns the wishlist published under alias together with its
+// items. storage.ErrListNotFound is returned for unknown aliases.
+func (s *Storage) GetList(ctx context.Context, alias string) (entity.WishList, []entity.GiftList, error) {
 	const op = "storage.postgres.GetList"
 
-	query := `
-	SELECT 
-	    items.gift_id, 
-	    items.name, 
-	    items.url, 
-	    wishlist.wishlist_id, 
-	    wishlist.name
-	FROM items
-	JOIN wishlist ON items.wishlist_id = wishlist.wishlist_id
-	WHERE wishlist.alias = $1;
-	`
-	rows, err := s.db.Query(query, alias)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	wl, err := scanWishList(s.db.QueryRowContext(ctx, `SELECT `+wishListColumns+` FROM wishlist WHERE alias = $1`, alias))
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.WishList{}, nil, fmt.Errorf("%s: %w", op, storage.ErrListNotFound)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return entity.WishList{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `SELECT ` + itemColumns + ` ` + itemFrom + `
+	WHERE items.wishlist_id = $1 ORDER BY items.position, items.gift_id`
+
+	rows, err := s.db.QueryContext(ctx, query, wl.WishListId)
+	if err != nil {
+		return entity.WishList{}, nil, fmt.Errorf("%s: %w", op, err)
 	}
 	defer rows.Close()
 
 	var list []entity.GiftList
 
 	for rows.Next() {
-		var l entity.GiftList
-
-		if err := rows.Scan(&l.GiftId, &l.Name, &l.Url, &l.WishListId, &l.WishListName); err != nil {
-			return nil, fmt.Errorf("%s: %w", op, err)
+		l, err := scanItem(rows)
+		if err != nil {
+			return entity.WishList{}, nil, fmt.Errorf("%s: %w", op, err)
 		}
 		list = append(list, l)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+		return entity.WishList{}, nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return list, nil
+	if err := s.loadClaims(ctx, list, false); err != nil {
+		return entity.WishList{}, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return wl, list, nil
 }
 
-func (s *Storage) GetAllLists(uid int) ([]entity.WishList, error) {
+func (s *Storage) GetAllLists(ctx context.Context, uid int) ([]entity.WishList, error) {
 	const op = "storage.postgres.GetAllLists"
 
-	query := `
-	SELECT 
-	    wishlist.wishlist_id, 
-	    wishlist.name,
-		wishlist.uid,
-		wishlist.alias
-	FROM wishlist
-	WHERE wishlist.uid = $1;
-	`
-	rows, err := s.db.Query(query, uid)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + wishListColumns + ` FROM wishlist WHERE uid = $1 ORDER BY wishlist_id`
+
+	rows, err := s.db.QueryContext(ctx, query, uid)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -137,9 +205,8 @@ func (s *Storage) GetAllLists(uid int) ([]entity.WishList, error) {
 	var list []entity.WishList
 
 	for rows.Next() {
-		var l entity.WishList
-
-		if err := rows.Scan(&l.WishListId, &l.Name, &l.UID, &l.Alias); err != nil {
+		l, err := scanWishList(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 		list = append(list, l)
@@ -152,20 +219,99 @@ func (s *Storage) GetAllLists(uid int) ([]entity.WishList, error) {
 	return list, nil
 }
 
-func (s *Storage) WishListDel(wishListId int) error {
+// UpdateList changes the fields set in upd and returns the resulting wishlist.
+func (s *Storage) UpdateList(ctx context.Context, wishListId, uid int, upd entity.WishListUpdate) (entity.WishList, error) {
+	const op = "storage.postgres.UpdateList"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.WishList{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = checkListOwner(ctx, tx, wishListId, uid); err != nil {
+		return entity.WishList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		set  []string
+		args []any
+	)
+
+	add := func(column string, value any) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if upd.Name != nil {
+		add("name", *upd.Name)
+	}
+	if upd.Description != nil {
+		add("description", *upd.Description)
+	}
+	if upd.EventDate != nil {
+		if *upd.EventDate == "" {
+			add("event_date", nil)
+		} else {
+			add("event_date", *upd.EventDate)
+		}
+	}
+	if upd.CoverEmoji != nil {
+		add("cover_emoji", *upd.CoverEmoji)
+	}
+	if upd.CoverColor != nil {
+		add("cover_color", *upd.CoverColor)
+	}
+	if upd.Surprise != nil {
+		add("surprise", *upd.Surprise)
+	}
+
+	if len(set) > 0 {
+		args = append(args, wishListId)
+		query := fmt.Sprintf(`UPDATE wishlist SET %s WHERE wishlist_id = $%d`, strings.Join(set, ", "), len(args))
+
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return entity.WishList{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	wl, err := scanWishList(tx.QueryRowContext(ctx, `SELECT `+wishListColumns+` FROM wishlist WHERE wishlist_id = $1`, wishListId))
+	if err != nil {
+		return entity.WishList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return entity.WishList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return wl, nil
+}
+
+func (s *Storage) WishListDel(ctx context.Context, wishListId, uid int) error {
 	const op = "storage.postgres.WishListDel"
 
-	tx, err := s.db.Begin()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if _, err = tx.Exec("DELETE FROM items WHERE wishlist_id = $1", wishListId); err != nil {
+	if err = checkListOwner(ctx, tx, wishListId, uid); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM items WHERE wishlist_id = $1", wishListId); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if _, err = tx.Exec("DELETE FROM wishlist WHERE wishlist_id = $1", wishListId); err != nil {
+	if _, err = tx.ExecContext(ctx, "DELETE FROM wishlist WHERE wishlist_id = $1", wishListId); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -177,19 +323,20 @@ func (s *Storage) WishListDel(wishListId int) error {
 	return nil
 }
 
-func (s *Storage) GetByWishId(wishListId int) ([]entity.GiftList, error) {
+func (s *Storage) GetByWishId(ctx context.Context, wishListId, uid int) ([]entity.GiftList, error) {
 	const op = "storage.postgres.GetByWishId"
 
-	query := `
-	SELECT 
-	    gift_id,
-	    wishlist_id,
-	    name,
-	    url
-	FROM items
-	WHERE wishlist_id = $1;
-	`
-	rows, err := s.db.Query(query, wishListId)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := checkListOwner(ctx, s.db, wishListId, uid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `SELECT ` + itemColumns + ` ` + itemFrom + `
+	WHERE items.wishlist_id = $1 ORDER BY items.position, items.gift_id`
+
+	rows, err := s.db.QueryContext(ctx, query, wishListId)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -198,9 +345,8 @@ func (s *Storage) GetByWishId(wishListId int) ([]entity.GiftList, error) {
 	var list []entity.GiftList
 
 	for rows.Next() {
-		var l entity.GiftList
-
-		if err := rows.Scan(&l.GiftId, &l.WishListId, &l.Name, &l.Url); err != nil {
+		l, err := scanItem(rows)
+		if err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 		list = append(list, l)
@@ -210,16 +356,1744 @@ func (s *Storage) GetByWishId(wishListId int) ([]entity.GiftList, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := s.loadClaims(ctx, list, true); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return list, nil
 }
 
-func (s *Storage) DelItemById(itemId int) error {
+func (s *Storage) DelItemById(ctx context.Context, itemId, uid int) error {
 	const op = "storage.postgres.DelItemById"
 
-	_, err := s.db.Exec(`DELETE FROM items WHERE gift_id = $1`, itemId)
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	DELETE FROM items
+	USING wishlist
+	WHERE items.gift_id = $1 AND items.wishlist_id = wishlist.wishlist_id AND wishlist.uid = $2;
+	`
+
+	res, err := s.db.ExecContext(ctx, query, itemId, uid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	// Nothing was deleted: tell a missing item from someone else's.
+	if err = checkItemOwner(ctx, s.db, itemId, uid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return fmt.Errorf("%s: %w", op, storage.ErrItemNotFound)
+}
+
+// UpdateItem changes the fields set in upd and returns the resulting item.
+func (s *Storage) UpdateItem(ctx context.Context, itemId, uid int, upd entity.GiftUpdate) (entity.GiftList, error) {
+	const op = "storage.postgres.UpdateItem"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = checkItemOwner(ctx, tx, itemId, uid); err != nil {
+		return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if upd.Url != nil {
+		var wishListId int
+
+		if err = tx.QueryRowContext(ctx, `SELECT wishlist_id FROM items WHERE gift_id = $1`, itemId).Scan(&wishListId); err != nil {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if err = checkUrlFree(ctx, tx, wishListId, itemId, *upd.Url); err != nil {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if upd.Price != nil || upd.ClearPrice {
+		var (
+			price    sql.NullInt64
+			currency sql.NullString
+			pledged  bool
+			surprise bool
+		)
+
+		query := `
+		SELECT
+		    items.price,
+		    items.currency,
+		    EXISTS (SELECT 1 FROM contributions WHERE contributions.gift_id = items.gift_id),
+		    wishlist.surprise
+		FROM items
+		JOIN wishlist ON items.wishlist_id = wishlist.wishlist_id
+		WHERE items.gift_id = $1
+		FOR UPDATE OF items;
+		`
+
+		if err = tx.QueryRowContext(ctx, query, itemId).Scan(&price, &currency, &pledged, &surprise); err != nil {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// Pledges were made towards the current price; only setting it
+		// to the same value is harmless.
+		same := upd.Price != nil && price.Valid && price.Int64 == upd.Price.Amount && currency.String == upd.Price.Currency
+		if pledged && !same {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, claimConflict(storage.ErrItemPledged, surprise))
+		}
+	}
+
+	if upd.Quantity != nil {
+		var (
+			reserved int
+			surprise bool
+		)
+
+		query := `
+		SELECT
+		    COALESCE((SELECT SUM(quantity) FROM reservations WHERE reservations.gift_id = items.gift_id), 0),
+		    wishlist.surprise
+		FROM items
+		JOIN wishlist ON items.wishlist_id = wishlist.wishlist_id
+		WHERE items.gift_id = $1
+		FOR UPDATE OF items;
+		`
+
+		if err = tx.QueryRowContext(ctx, query, itemId).Scan(&reserved, &surprise); err != nil {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if *upd.Quantity < reserved {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, claimConflict(storage.ErrQuantityBelow, surprise))
+		}
+	}
+
+	var (
+		set  []string
+		args []any
+	)
+
+	add := func(column string, value any) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if upd.Name != nil {
+		add("name", *upd.Name)
+	}
+	if upd.Url != nil {
+		// The status of the old link says nothing about the new one.
+		add("url", *upd.Url)
+		add("link_status", nil)
+		add("link_checked_at", nil)
+	}
+	if upd.Description != nil {
+		add("description", *upd.Description)
+	}
+	if upd.ImageUrl != nil {
+		add("image_url", *upd.ImageUrl)
+	}
+	if upd.Options != nil {
+		options, err := optionsJSON(*upd.Options)
+		if err != nil {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+		}
+		add("options", options)
+	}
+	if upd.Price != nil {
+		add("price", upd.Price.Amount)
+		add("currency", upd.Price.Currency)
+	} else if upd.ClearPrice {
+		add("price", nil)
+		add("currency", nil)
+	}
+	if upd.PriceAlert != nil {
+		add("price_alert", upd.PriceAlert.Amount)
+		add("price_alert_currency", upd.PriceAlert.Currency)
+	} else if upd.ClearAlert {
+		add("price_alert", nil)
+		add("price_alert_currency", nil)
+	}
+	if upd.Quantity != nil {
+		add("quantity", *upd.Quantity)
+	}
+	if upd.Priority != nil {
+		add("priority", *upd.Priority)
+	}
+
+	if len(set) > 0 {
+		args = append(args, itemId)
+		query := fmt.Sprintf(`UPDATE items SET %s WHERE gift_id = $%d`, strings.Join(set, ", "), len(args))
+
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	query := `SELECT ` + itemColumns + ` ` + itemFrom + `
+	WHERE items.gift_id = $1`
+
+	l, err := scanItem(tx.QueryRowContext(ctx, query, itemId))
+	if err != nil {
+		return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	list := []entity.GiftList{l}
+	if err = s.loadClaims(ctx, list, true); err != nil {
+		return entity.GiftList{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return list[0], nil
+}
+
+// ReorderItems rewrites the positions of the wishlist items in the order of
+// giftIds, which must list every item of the wishlist exactly once.
+// storage.ErrOrderMismatch is returned otherwise.
+func (s *Storage) ReorderItems(ctx context.Context, wishListId, uid int, giftIds []int) error {
+	const op = "storage.postgres.ReorderItems"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = checkListOwner(ctx, tx, wishListId, uid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT gift_id FROM items WHERE wishlist_id = $1 FOR UPDATE`, wishListId)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	current := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		current[id] = true
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !sameItems(current, giftIds) {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrderMismatch)
+	}
+
+	query := `
+	UPDATE items SET position = ordered.position
+	FROM unnest($1::int[]) WITH ORDINALITY AS ordered(gift_id, position)
+	WHERE items.gift_id = ordered.gift_id;
+	`
+
+	if _, err = tx.ExecContext(ctx, query, pq.Array(giftIds)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FillItem copies details found on an item's page into the fields the
+// owner left empty. Nothing changes when the item is gone or its URL no
+// longer matches url.
+func (s *Storage) FillItem(ctx context.Context, itemId int, url string, meta entity.GiftMetadata) error {
+	const op = "storage.postgres.FillItem"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var price, currency any
+	if meta.Price != nil {
+		price, currency = meta.Price.Amount, meta.Price.Currency
+	}
+
+	query := `
+	UPDATE items SET
+		name = CASE WHEN name = '' THEN $3 ELSE name END,
+		image_url = CASE WHEN image_url = '' THEN $4 ELSE image_url END,
+		price = CASE WHEN price IS NULL THEN $5 ELSE price END,
+		currency = CASE WHEN price IS NULL THEN $6 ELSE currency END
+	WHERE gift_id = $1 AND url = $2;
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, itemId, url, meta.Name, meta.ImageUrl, price, currency); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
+
+// CountClick records an outbound click on the item's link and returns the
+// link. Only items of published wishlists, those with a share alias, are
+// found.
+func (s *Storage) CountClick(ctx context.Context, itemId int) (string, error) {
+	const op = "storage.postgres.CountClick"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var url string
+
+	query := `
+	UPDATE items SET clicks = clicks + 1
+	FROM wishlist
+	WHERE items.gift_id = $1 AND items.wishlist_id = wishlist.wishlist_id AND wishlist.alias <> ''
+	RETURNING items.url;
+	`
+
+	err := s.db.QueryRowContext(ctx, query, itemId).Scan(&url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrItemNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return url, nil
+}
+
+// TrackedItems returns up to limit items with a web URL whose price was
+// last checked before checkedBefore, least recently checked first.
+func (s *Storage) TrackedItems(ctx context.Context, checkedBefore time.Time, limit int) ([]entity.TrackedItem, error) {
+	const op = "storage.postgres.TrackedItems"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT items.gift_id, items.wishlist_id, wishlist.uid, items.name, items.url,
+	    last.price, last.currency, items.price_alert, items.price_alert_currency
+	FROM items
+	JOIN wishlist ON items.wishlist_id = wishlist.wishlist_id
+	LEFT JOIN LATERAL (
+	    SELECT price, currency FROM price_history
+	    WHERE price_history.gift_id = items.gift_id
+	    ORDER BY checked_at DESC, id DESC
+	    LIMIT 1
+	) AS last ON true
+	WHERE (items.url LIKE 'http://%' OR items.url LIKE 'https://%')
+	  AND (items.price_checked_at IS NULL OR items.price_checked_at < $1)
+	ORDER BY items.price_checked_at NULLS FIRST, items.gift_id
+	LIMIT $2;
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var list []entity.TrackedItem
+
+	for rows.Next() {
+		var (
+			it                          entity.TrackedItem
+			last, alert                 sql.NullInt64
+			lastCurrency, alertCurrency sql.NullString
+		)
+
+		err := rows.Scan(&it.GiftId, &it.WishListId, &it.UID, &it.Name, &it.Url,
+			&last, &lastCurrency, &alert, &alertCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if last.Valid {
+			it.LastPrice = &entity.Price{Amount: last.Int64, Currency: lastCurrency.String}
+		}
+		if alert.Valid {
+			it.PriceAlert = &entity.Price{Amount: alert.Int64, Currency: alertCurrency.String}
+		}
+
+		list = append(list, it)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return list, nil
+}
+
+// RecordPrice marks the item as checked at checkedAt and, when the page
+// showed a price, appends it to the item's price history.
+func (s *Storage) RecordPrice(ctx context.Context, itemId int, price *entity.Price, checkedAt time.Time) error {
+	const op = "storage.postgres.RecordPrice"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE items SET price_checked_at = $2 WHERE gift_id = $1`, itemId, checkedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrItemNotFound)
+	}
+
+	if price != nil {
+		query := `INSERT INTO price_history (gift_id, price, currency, checked_at) VALUES ($1, $2, $3, $4)`
+
+		if _, err = tx.ExecContext(ctx, query, itemId, price.Amount, price.Currency, checkedAt); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// PriceHistory returns the prices observed on the item's page, oldest
+// first.
+func (s *Storage) PriceHistory(ctx context.Context, itemId, uid int) ([]entity.PricePoint, error) {
+	const op = "storage.postgres.PriceHistory"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := checkItemOwner(ctx, s.db, itemId, uid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `SELECT price, currency, checked_at FROM price_history WHERE gift_id = $1 ORDER BY checked_at, id`
+
+	rows, err := s.db.QueryContext(ctx, query, itemId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var list []entity.PricePoint
+
+	for rows.Next() {
+		var p entity.PricePoint
+		if err := rows.Scan(&p.Amount, &p.Currency, &p.CheckedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		list = append(list, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return list, nil
+}
+
+// LinkItems returns up to limit items with a web URL whose link was last
+// checked before checkedBefore, least recently checked first.
+func (s *Storage) LinkItems(ctx context.Context, checkedBefore time.Time, limit int) ([]entity.LinkItem, error) {
+	const op = "storage.postgres.LinkItems"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT gift_id, url FROM items
+	WHERE (url LIKE 'http://%' OR url LIKE 'https://%')
+	  AND (link_checked_at IS NULL OR link_checked_at < $1)
+	ORDER BY link_checked_at NULLS FIRST, gift_id
+	LIMIT $2;
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var list []entity.LinkItem
+
+	for rows.Next() {
+		var it entity.LinkItem
+		if err := rows.Scan(&it.GiftId, &it.Url); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		list = append(list, it)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return list, nil
+}
+
+// RecordLink saves the result of a link check. Nothing changes when the
+// item is gone or its URL no longer matches url.
+func (s *Storage) RecordLink(ctx context.Context, itemId int, url, status string, checkedAt time.Time) error {
+	const op = "storage.postgres.RecordLink"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE items SET link_status = $3, link_checked_at = $4 WHERE gift_id = $1 AND url = $2`
+
+	if _, err := s.db.ExecContext(ctx, query, itemId, url, status, checkedAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MoveItems moves items of uid into the wishlist wishListId, after its
+// current items and in the given order. Reservations and pledges stay
+// with the items; items already in the wishlist keep their place.
+func (s *Storage) MoveItems(ctx context.Context, wishListId, uid int, giftIds []int) error {
+	const op = "storage.postgres.MoveItems"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = checkListOwner(ctx, tx, wishListId, uid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	items, err := lockOwnItems(ctx, tx, uid, giftIds)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		moving []int
+		urls   []string
+	)
+
+	for _, id := range giftIds {
+		if it := items[id]; it.wishListId != wishListId {
+			moving = append(moving, id)
+			urls = append(urls, it.url)
+		}
+	}
+
+	if len(moving) == 0 {
+		return nil
+	}
+
+	if err = checkUrlsFree(ctx, tx, wishListId, moving, urls); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+	UPDATE items SET
+		wishlist_id = $1,
+		position = (SELECT COALESCE(MAX(position), 0) FROM items WHERE wishlist_id = $1) + ordered.n
+	FROM unnest($2::int[]) WITH ORDINALITY AS ordered(gift_id, n)
+	WHERE items.gift_id = ordered.gift_id;
+	`
+
+	if _, err = tx.ExecContext(ctx, query, wishListId, pq.Array(moving)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// CopyItems copies items of uid into the wishlist wishListId, after its
+// current items and in the given order, and returns the ids of the copies
+// in that order. Copies start without reservations, pledges, price history
+// or link checks.
+func (s *Storage) CopyItems(ctx context.Context, wishListId, uid int, giftIds []int) ([]int, error) {
+	const op = "storage.postgres.CopyItems"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if err = checkListOwner(ctx, tx, wishListId, uid); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	items, err := lockOwnItems(ctx, tx, uid, giftIds)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	urls := make([]string, 0, len(giftIds))
+	for _, id := range giftIds {
+		urls = append(urls, items[id].url)
+	}
+
+	if err = checkUrlsFree(ctx, tx, wishListId, nil, urls); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := `
+	INSERT INTO items (wishlist_id, name, url, description, image_url, options, price, currency,
+		price_alert, price_alert_currency, quantity, priority, position)
+	SELECT $1, items.name, items.url, items.description, items.image_url, items.options, items.price, items.currency,
+		items.price_alert, items.price_alert_currency, items.quantity, items.priority,
+		(SELECT COALESCE(MAX(position), 0) FROM items WHERE wishlist_id = $1) + ordered.n
+	FROM unnest($2::int[]) WITH ORDINALITY AS ordered(gift_id, n)
+	JOIN items ON items.gift_id = ordered.gift_id
+	RETURNING gift_id, position;
+	`
+
+	rows, err := tx.QueryContext(ctx, query, wishListId, pq.Array(giftIds))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	type copied struct{ id, position int }
+	var created []copied
+
+	for rows.Next() {
+		var c copied
+		if err = rows.Scan(&c.id, &c.position); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		created = append(created, c)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// RETURNING does not promise any order; positions follow giftIds.
+	sort.Slice(created, func(i, j int) bool { return created[i].position < created[j].position })
+
+	ids := make([]int, 0, len(created))
+	for _, c := range created {
+		ids = append(ids, c.id)
+	}
+
+	return ids, nil
+}
+
+// sameItems reports whether ids lists every key of current exactly once.
+func sameItems(current map[int]bool, ids []int) bool {
+	if len(ids) != len(current) {
+		return false
+	}
+
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !current[id] || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+
+	return true
+}
+
+// ReserveItem claims quantity units of an item of the wishlist published
+// under alias, adding to the units r already holds. The item row stays
+// locked while the units are counted, so concurrent claims can never take
+// more than the item quantity.
+func (s *Storage) ReserveItem(ctx context.Context, alias string, itemId int, r entity.Reserver, quantity int) error {
+	const op = "storage.postgres.ReserveItem"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	owner, err := lockSharedItem(ctx, tx, alias, itemId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if r.UID != 0 && r.UID == owner {
+		return fmt.Errorf("%s: %w", op, storage.ErrForbidden)
+	}
+
+	var (
+		funded   bool
+		total    int
+		reserved int
+	)
+
+	query := `
+	SELECT 
+	    EXISTS (SELECT 1 FROM contributions WHERE contributions.gift_id = items.gift_id),
+	    items.quantity,
+	    COALESCE((SELECT SUM(quantity) FROM reservations WHERE reservations.gift_id = items.gift_id), 0)
+	FROM items
+	WHERE items.gift_id = $1;
+	`
+
+	if err = tx.QueryRowContext(ctx, query, itemId).Scan(&funded, &total, &reserved); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if funded {
+		return fmt.Errorf("%s: %w", op, storage.ErrItemFunded)
+	}
+	if reserved+quantity > total {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotEnoughLeft)
+	}
+
+	cond, holder := reserverCond("reserver_uid", r, 2)
+
+	res, err := tx.ExecContext(ctx, `UPDATE reservations SET quantity = quantity + $3, name = $4 WHERE gift_id = $1 AND `+cond,
+		itemId, holder, quantity, r.Name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n == 0 {
+		var reserverUID, guestId any
+		if r.UID != 0 {
+			reserverUID = r.UID
+		} else {
+			guestId = r.GuestId
+		}
+
+		query = `
+		INSERT INTO reservations (gift_id, reserver_uid, guest_id, name, quantity)
+		VALUES ($1, $2, $3, $4, $5);
+		`
+
+		if _, err = tx.ExecContext(ctx, query, itemId, reserverUID, guestId, r.Name, quantity); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnreserveItem releases all units of an item reserved by r.
+func (s *Storage) UnreserveItem(ctx context.Context, alias string, itemId int, r entity.Reserver) error {
+	const op = "storage.postgres.UnreserveItem"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err = lockSharedItem(ctx, tx, alias, itemId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	cond, holder := reserverCond("reserver_uid", r, 2)
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM reservations WHERE gift_id = $1 AND `+cond, itemId, holder)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotReserved)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Pledge adds a contribution towards the price of a shared item and returns
+// its id. Pledges never exceed the price; the one reaching it marks the item
+// fully funded. The item row lock serializes concurrent pledges.
+func (s *Storage) Pledge(ctx context.Context, alias string, itemId int, r entity.Reserver, amount int64) (int, error) {
+	const op = "storage.postgres.Pledge"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	owner, err := lockSharedItem(ctx, tx, alias, itemId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if r.UID != 0 && r.UID == owner {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrForbidden)
+	}
+
+	var (
+		price    sql.NullInt64
+		reserved bool
+		pledged  int64
+	)
+
+	query := `
+	SELECT 
+	    items.price,
+	    EXISTS (SELECT 1 FROM reservations WHERE reservations.gift_id = items.gift_id),
+	    COALESCE((SELECT SUM(amount) FROM contributions WHERE contributions.gift_id = items.gift_id), 0)
+	FROM items
+	WHERE items.gift_id = $1;
+	`
+
+	if err = tx.QueryRowContext(ctx, query, itemId).Scan(&price, &reserved, &pledged); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !price.Valid {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrNoPrice)
+	}
+	if reserved {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrItemReserved)
+	}
+	if amount > price.Int64-pledged {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrOverfunded)
+	}
+
+	var contributorUID, guestId any
+	if r.UID != 0 {
+		contributorUID = r.UID
+	} else {
+		guestId = r.GuestId
+	}
+
+	var id int
+
+	query = `
+	INSERT INTO contributions (gift_id, contributor_uid, guest_id, name, amount)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING contribution_id;
+	`
+
+	if err = tx.QueryRowContext(ctx, query, itemId, contributorUID, guestId, r.Name, amount).Scan(&id); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if amount == price.Int64-pledged {
+		if _, err = tx.ExecContext(ctx, `UPDATE items SET funded_at = now() WHERE gift_id = $1`, itemId); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Withdraw removes a contribution pledged by r. The item is no longer
+// fully funded afterwards.
+func (s *Storage) Withdraw(ctx context.Context, alias string, itemId, contributionId int, r entity.Reserver) error {
+	const op = "storage.postgres.Withdraw"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	if _, err = lockSharedItem(ctx, tx, alias, itemId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var (
+		c              entity.Contribution
+		contributorUID sql.NullInt64
+		guestId        sql.NullString
+	)
+
+	query := `SELECT contributor_uid, guest_id FROM contributions WHERE contribution_id = $1 AND gift_id = $2`
+
+	err = tx.QueryRowContext(ctx, query, contributionId, itemId).Scan(&contributorUID, &guestId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, storage.ErrContributionNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	c.ContributorUID, c.GuestId = int(contributorUID.Int64), guestId.String
+	if !c.MadeBy(r) {
+		return fmt.Errorf("%s: %w", op, storage.ErrForbidden)
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM contributions WHERE contribution_id = $1`, contributionId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE items SET funded_at = NULL WHERE gift_id = $1`, itemId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateUser(ctx context.Context, login, passwordHash string) (int, error) {
+	const op = "storage.postgres.CreateUser"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var uid int
+
+	query := `
+		INSERT INTO wish_users (login, password_hash) VALUES ($1, $2) RETURNING uid;
+		`
+
+	err := s.db.QueryRowContext(ctx, query, login, passwordHash).Scan(&uid)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return uid, nil
+}
+
+func (s *Storage) GetUserByLogin(ctx context.Context, login string) (entity.User, error) {
+	const op = "storage.postgres.GetUserByLogin"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var u entity.User
+
+	query := `
+	SELECT uid, login, password_hash
+	FROM wish_users
+	WHERE login = $1;
+	`
+
+	err := s.db.QueryRowContext(ctx, query, login).Scan(&u.UID, &u.Login, &u.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	if err != nil {
+		return entity.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return u, nil
+}
+
+// GetOrCreateExternalUser maps an identity asserted by an external provider
+// to a local uid, creating a password-less user on first login. Such users
+// have no login, so no local account can claim their identity.
+func (s *Storage) GetOrCreateExternalUser(ctx context.Context, provider, subject, email string) (int, error) {
+	const op = "storage.postgres.GetOrCreateExternalUser"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	uid, err := externalUser(ctx, s.db, provider, subject)
+	if err == nil {
+		return uid, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// an empty hash never matches, so these users can only log in through
+	// the provider
+	if err = tx.QueryRowContext(ctx, `INSERT INTO wish_users (login, password_hash) VALUES (NULL, '') RETURNING uid`).Scan(&uid); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	insert := `
+		INSERT INTO user_identities (provider, subject, uid, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, subject) DO NOTHING
+		RETURNING uid;
+		`
+
+	err = tx.QueryRowContext(ctx, insert, provider, subject, uid, email).Scan(&uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A concurrent first login linked the identity; drop our user and
+		// use theirs.
+		tx.Rollback()
+
+		if uid, err = externalUser(ctx, s.db, provider, subject); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return uid, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return uid, nil
+}
+
+func externalUser(ctx context.Context, q querier, provider, subject string) (int, error) {
+	var uid int
+
+	err := q.QueryRowContext(ctx, `SELECT uid FROM user_identities WHERE provider = $1 AND subject = $2`, provider, subject).Scan(&uid)
+
+	return uid, err
+}
+
+func (s *Storage) CreateRefreshToken(ctx context.Context, uid int, familyId, tokenHash string, expiresAt time.Time) error {
+	const op = "storage.postgres.CreateRefreshToken"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO refresh_tokens (uid, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4);
+		`
+
+	if _, err := s.db.ExecContext(ctx, query, uid, familyId, tokenHash, expiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RotateRefreshToken marks the refresh token as used and stores its
+// successor in the same family. Presenting an already used or revoked token
+// revokes the whole family and returns storage.ErrTokenReused.
+func (s *Storage) RotateRefreshToken(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (int, error) {
+	const op = "storage.postgres.RotateRefreshToken"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		uid       int
+		familyId  string
+		expires   time.Time
+		usedAt    sql.NullTime
+		revokedAt sql.NullTime
+	)
+
+	query := `
+	SELECT uid, family_id, expires_at, used_at, revoked_at
+	FROM refresh_tokens
+	WHERE token_hash = $1
+	FOR UPDATE;
+	`
+
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&uid, &familyId, &expires, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if usedAt.Valid || revokedAt.Valid {
+		if _, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`, familyId); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err = tx.Commit(); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrTokenReused)
+	}
+
+	if time.Now().After(expires) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrTokenExpired)
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1`, tokenHash); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	insert := `
+		INSERT INTO refresh_tokens (uid, family_id, token_hash, expires_at) VALUES ($1, $2, $3, $4);
+		`
+
+	if _, err = tx.ExecContext(ctx, insert, uid, familyId, newHash, expiresAt); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return uid, nil
+}
+
+// RevokeRefreshFamily revokes every refresh token sharing a family with the
+// given one, provided it belongs to uid.
+func (s *Storage) RevokeRefreshFamily(ctx context.Context, uid int, tokenHash string) error {
+	const op = "storage.postgres.RevokeRefreshFamily"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	UPDATE refresh_tokens SET revoked_at = now()
+	WHERE revoked_at IS NULL AND family_id = (
+		SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND uid = $2
+	);
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, tokenHash, uid); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	const op = "storage.postgres.RevokeToken"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING;
+		`
+
+	if _, err := s.db.ExecContext(ctx, query, jti, expiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	const op = "storage.postgres.IsTokenRevoked"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var revoked bool
+
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpiredTokens drops denylist entries and refresh tokens that can no
+// longer be used. It returns the number of removed rows.
+func (s *Storage) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	const op = "storage.postgres.DeleteExpiredTokens"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var total int64
+
+	for _, query := range []string{
+		`DELETE FROM revoked_tokens WHERE expires_at < now()`,
+		`DELETE FROM refresh_tokens WHERE expires_at < now()`,
+	} {
+		res, err := s.db.ExecContext(ctx, query)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", op, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", op, err)
+		}
+		total += n
+	}
+
+	return total, nil
+}
+
+func (s *Storage) CreateAPIToken(ctx context.Context, uid int, name, tokenHash string, scopes []string) (int, error) {
+	const op = "storage.postgres.CreateAPIToken"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+
+	query := `
+		INSERT INTO api_tokens (uid, name, token_hash, scopes) VALUES ($1, $2, $3, $4) RETURNING token_id;
+		`
+
+	err := s.db.QueryRowContext(ctx, query, uid, name, tokenHash, pq.Array(scopes)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetAPITokens(ctx context.Context, uid int) ([]entity.APIToken, error) {
+	const op = "storage.postgres.GetAPITokens"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT token_id, name, scopes, created_at, last_used_at
+	FROM api_tokens
+	WHERE uid = $1 AND revoked_at IS NULL
+	ORDER BY token_id;
+	`
+	rows, err := s.db.QueryContext(ctx, query, uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var list []entity.APIToken
+
+	for rows.Next() {
+		var (
+			t        entity.APIToken
+			lastUsed sql.NullTime
+		)
+
+		if err := rows.Scan(&t.TokenId, &t.Name, pq.Array(&t.Scopes), &t.CreatedAt, &lastUsed); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		list = append(list, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return list, nil
+}
+
+func (s *Storage) RevokeAPIToken(ctx context.Context, tokenId, uid int) error {
+	const op = "storage.postgres.RevokeAPIToken"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE api_tokens SET revoked_at = now() WHERE token_id = $1 AND uid = $2 AND revoked_at IS NULL`
+
+	res, err := s.db.ExecContext(ctx, query, tokenId, uid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// Tokens of other users are reported as missing, so token ids of other
+	// accounts cannot be probed.
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+	}
+
+	return nil
+}
+
+// UseAPIToken resolves an active API token by hash and records its use.
+func (s *Storage) UseAPIToken(ctx context.Context, tokenHash string) (entity.APIToken, int, error) {
+	const op = "storage.postgres.UseAPIToken"
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var (
+		t   entity.APIToken
+		uid int
+	)
+
+	query := `
+	UPDATE api_tokens SET last_used_at = now()
+	WHERE token_hash = $1 AND revoked_at IS NULL
+	RETURNING token_id, uid, name, scopes, created_at, last_used_at;
+	`
+
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&t.TokenId, &uid, &t.Name, pq.Array(&t.Scopes), &t.CreatedAt, &t.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity.APIToken{}, 0, fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+	}
+	if err != nil {
+		return entity.APIToken{}, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return t, uid, nil
+}
+
+// wishListColumns is the select list scanWishList expects.
+const wishListColumns = `wishlist_id, name, uid, alias, description,
+	COALESCE(to_char(event_date, 'YYYY-MM-DD'), ''), cover_emoji, cover_color, surprise`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWishList(row scanner) (entity.WishList, error) {
+	var l entity.WishList
+
+	err := row.Scan(&l.WishListId, &l.Name, &l.UID, &l.Alias, &l.Description, &l.EventDate, &l.CoverEmoji, &l.CoverColor, &l.Surprise)
+
+	return l, err
+}
+
+// itemColumns is the select list scanItem expects, to be used with itemFrom.
+const itemColumns = `items.gift_id, items.wishlist_id, wishlist.name, items.name, items.url, items.clicks,
+	items.description, items.image_url, items.options, items.price, items.currency, items.price_alert, items.price_alert_currency,
+	items.link_status, items.link_checked_at, items.quantity, items.priority, items.position`
+
+const itemFrom = `FROM items
+	JOIN wishlist ON items.wishlist_id = wishlist.wishlist_id`
+
+func scanItem(row scanner) (entity.GiftList, error) {
+	var (
+		l             entity.GiftList
+		options       []byte
+		price         sql.NullInt64
+		currency      sql.NullString
+		alert         sql.NullInt64
+		alertCurrency sql.NullString
+		linkStatus    sql.NullString
+		linkCheckedAt sql.NullTime
+	)
+
+	err := row.Scan(&l.GiftId, &l.WishListId, &l.WishListName, &l.Name, &l.Url, &l.Clicks, &l.Description, &l.ImageUrl, &options,
+		&price, &currency, &alert, &alertCurrency, &linkStatus, &linkCheckedAt, &l.Quantity, &l.Priority, &l.Position)
+	if err != nil {
+		return l, err
+	}
+
+	if err = json.Unmarshal(options, &l.Options); err != nil {
+		return l, err
+	}
+
+	if price.Valid {
+		l.Price = &entity.Price{Amount: price.Int64, Currency: currency.String}
+	}
+	if alert.Valid {
+		l.PriceAlert = &entity.Price{Amount: alert.Int64, Currency: alertCurrency.String}
+	}
+	if linkStatus.Valid {
+		l.Link = entity.NewLinkHealth(linkStatus.String, linkCheckedAt.Time)
+	}
+
+	return l, nil
+}
+
+// optionsJSON encodes item options for the JSONB column; nil becomes an
+// empty object.
+func optionsJSON(o entity.Options) ([]byte, error) {
+	if o == nil {
+		o = entity.Options{}
+	}
+
+	return json.Marshal(o)
+}
+
+// loadClaims attaches reservations, the remaining quantity and group gift
+// progress to the items of a single wishlist. In the owner's view nothing
+// is attached while the wishlist is in surprise mode.
+func (s *Storage) loadClaims(ctx context.Context, list []entity.GiftList, ownerView bool) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	var surprise bool
+
+	err := s.db.QueryRowContext(ctx, `SELECT surprise FROM wishlist WHERE wishlist_id = $1`, list[0].WishListId).Scan(&surprise)
+	if err != nil {
+		return err
+	}
+	if ownerView && surprise {
+		return nil
+	}
+
+	byId := make(map[int]*entity.GiftList, len(list))
+	for i := range list {
+		remaining := list[i].Quantity
+		list[i].Remaining = &remaining
+		byId[list[i].GiftId] = &list[i]
+	}
+
+	if err := s.loadReservations(ctx, list[0].WishListId, byId); err != nil {
+		return err
+	}
+
+	return s.loadFunding(ctx, list[0].WishListId, byId)
+}
+
+func (s *Storage) loadReservations(ctx context.Context, wishListId int, byId map[int]*entity.GiftList) error {
+	query := `
+	SELECT 
+	    reservations.gift_id,
+	    reservations.reserver_uid,
+	    reservations.guest_id,
+	    reservations.name,
+	    reservations.quantity,
+	    reservations.created_at
+	FROM reservations
+	JOIN items ON reservations.gift_id = items.gift_id
+	WHERE items.wishlist_id = $1
+	ORDER BY reservations.reservation_id;
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, wishListId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			res         entity.Reservation
+			giftId      int
+			reserverUID sql.NullInt64
+			guestId     sql.NullString
+		)
+
+		if err := rows.Scan(&giftId, &reserverUID, &guestId, &res.Name, &res.Quantity, &res.CreatedAt); err != nil {
+			return err
+		}
+		res.ReserverUID, res.GuestId = int(reserverUID.Int64), guestId.String
+
+		if it, ok := byId[giftId]; ok {
+			it.Reservations = append(it.Reservations, res)
+			*it.Remaining -= res.Quantity
+		}
+	}
+
+	return rows.Err()
+}
+
+// loadFunding attaches group gift progress to the priced items that have
+// contributions.
+func (s *Storage) loadFunding(ctx context.Context, wishListId int, byId map[int]*entity.GiftList) error {
+	query := `
+	SELECT 
+	    contributions.contribution_id,
+	    contributions.gift_id,
+	    contributions.contributor_uid,
+	    contributions.guest_id,
+	    contributions.name,
+	    contributions.amount,
+	    contributions.created_at,
+	    items.funded_at IS NOT NULL
+	FROM contributions
+	JOIN items ON contributions.gift_id = items.gift_id
+	WHERE items.wishlist_id = $1
+	ORDER BY contributions.contribution_id;
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, wishListId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			c              entity.Contribution
+			giftId         int
+			contributorUID sql.NullInt64
+			guestId        sql.NullString
+			funded         bool
+		)
+
+		err := rows.Scan(&c.ContributionId, &giftId, &contributorUID, &guestId, &c.Name, &c.Amount, &c.CreatedAt, &funded)
+		if err != nil {
+			return err
+		}
+		c.ContributorUID, c.GuestId = int(contributorUID.Int64), guestId.String
+
+		it, ok := byId[giftId]
+		if !ok || it.Price == nil {
+			continue
+		}
+
+		if it.Funding == nil {
+			it.Funding = &entity.Funding{Target: it.Price.Amount, Currency: it.Price.Currency, FullyFunded: funded}
+		}
+		it.Funding.Pledged += c.Amount
+		it.Funding.Contributions = append(it.Funding.Contributions, c)
+	}
+
+	return rows.Err()
+}
+
+// reserverCond selects the rows made by r: by account when r has a uid,
+// stored in uidColumn, and by guest id otherwise. arg is the placeholder
+// number for the returned value.
+func reserverCond(uidColumn string, r entity.Reserver, arg int) (string, any) {
+	if r.UID != 0 {
+		return fmt.Sprintf("%s = $%d", uidColumn, arg), r.UID
+	}
+	return fmt.Sprintf("guest_id = $%d", arg), r.GuestId
+}
+
+// lockSharedItem locks an item of the wishlist published under alias and
+// returns the wishlist owner.
+func lockSharedItem(ctx context.Context, tx *sql.Tx, alias string, itemId int) (int, error) {
+	var owner int
+
+	query := `
+	SELECT wishlist.uid
+	FROM items
+	JOIN wishlist ON items.wishlist_id = wishlist.wishlist_id
+	WHERE items.gift_id = $1 AND wishlist.alias = $2
+	FOR UPDATE OF items;
+	`
+
+	err := tx.QueryRowContext(ctx, query, itemId, alias).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, storage.ErrItemNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return owner, nil
+}
+
+// checkUrlFree returns storage.ErrItemExists when an item of the wishlist
+// other than exceptId already links to url. The wishlist row stays locked
+// until the end of tx, so concurrent writers cannot both pass the check.
+func checkUrlFree(ctx context.Context, tx *sql.Tx, wishListId, exceptId int, url string) error {
+	return checkUrlsFree(ctx, tx, wishListId, []int{exceptId}, []string{url})
+}
+
+// checkUrlsFree is checkUrlFree for several items joining the wishlist at
+// once; their urls must also differ from each other.
+func checkUrlsFree(ctx context.Context, tx *sql.Tx, wishListId int, exceptIds []int, urls []string) error {
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		if seen[u] {
+			return storage.ErrItemExists
+		}
+		seen[u] = true
+	}
+
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM wishlist WHERE wishlist_id = $1 FOR NO KEY UPDATE`, wishListId); err != nil {
+		return err
+	}
+
+	if exceptIds == nil {
+		exceptIds = []int{}
+	}
+
+	var exists bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM items WHERE wishlist_id = $1 AND url = ANY($2) AND gift_id <> ALL($3))`
+
+	if err := tx.QueryRowContext(ctx, query, wishListId, pq.Array(urls), pq.Array(exceptIds)).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return storage.ErrItemExists
+	}
+
+	return nil
+}
+
+// ownItem is the part of a locked item MoveItems and CopyItems need.
+type ownItem struct {
+	wishListId int
+	url        string
+}
+
+// lockOwnItems locks the items and returns them by id. It returns
+// storage.ErrItemNotFound if any of them does not exist and
+// storage.ErrForbidden if any belongs to another user.
+func lockOwnItems(ctx context.Context, tx *sql.Tx, uid int, giftIds []int) (map[int]ownItem, error) {
+	query := `
+	SELECT items.gift_id, items.wishlist_id, items.url, wishlist.uid
+	FROM items
+	JOIN wishlist ON items.wishlist_id = wishlist.wishlist_id
+	WHERE items.gift_id = ANY($1)
+	FOR UPDATE OF items;
+	`
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(giftIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[int]ownItem, len(giftIds))
+	forbidden := false
+
+	for rows.Next() {
+		var (
+			id, owner int
+			it        ownItem
+		)
+		if err := rows.Scan(&id, &it.wishListId, &it.url, &owner); err != nil {
+			return nil, err
+		}
+		if owner != uid {
+			forbidden = true
+		}
+		items[id] = it
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range giftIds {
+		if _, ok := items[id]; !ok {
+			return nil, storage.ErrItemNotFound
+		}
+	}
+	if forbidden {
+		return nil, storage.ErrForbidden
+	}
+
+	return items, nil
+}
+
+// checkListOwner returns storage.ErrListNotFound if the wishlist does not
+// exist and storage.ErrForbidden if it belongs to another user.
+func checkListOwner(ctx context.Context, q querier, wishListId, uid int) error {
+	var owner int
+
+	err := q.QueryRowContext(ctx, `SELECT uid FROM wishlist WHERE wishlist_id = $1`, wishListId).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrListNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if owner != uid {
+		return storage.ErrForbidden
+	}
+
+	return nil
+}
+
+// checkItemOwner is the item counterpart of checkListOwner.
+// claimConflict returns err, or storage.ErrHiddenClaims when the wishlist
+// is in surprise mode and its owner may not learn about reservations and
+// pledges.
+func claimConflict(err error, surprise bool) error {
+	if surprise {
+		return storage.ErrHiddenClaims
+	}
+	return err
+}
+
+func checkItemOwner(ctx context.Context, q querier, itemId, uid int) error {
+	var owner int
+
+	query := `
+	SELECT wishlist.uid
+	FROM items
+	JOIN wishlist ON items.wishlist_id = wishlist.wishlist_id
+	WHERE items.gift_id = $1;
+	`
+
+	err := q.QueryRowContext(ctx, query, itemId).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrItemNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if owner != uid {
+		return storage.ErrForbidden
+	}
+
+	return nil
+}
+
+var _ storage.Store = (*Storage)(nil)
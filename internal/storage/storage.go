@@ -1,8 +1,82 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"time"
+	"wish_list/internal/entity"
+)
 
 var (
-	ErrListNotFound = errors.New("list not found")
-	ErrListExists   = errors.New("list exists")
+	ErrListNotFound  = errors.New("list not found")
+	ErrListExists    = errors.New("list exists")
+	ErrItemNotFound  = errors.New("item not found")
+	ErrForbidden     = errors.New("access denied")
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUserExists    = errors.New("user exists")
+	ErrTokenNotFound = errors.New("token not found")
+	ErrTokenExpired  = errors.New("token expired")
+	ErrTokenReused   = errors.New("token reused")
+	ErrItemReserved  = errors.New("item already reserved")
+	ErrNotReserved   = errors.New("item not reserved")
+	ErrItemFunded    = errors.New("item is a group gift")
+	ErrNoPrice       = errors.New("item has no price")
+	ErrOverfunded    = errors.New("pledge exceeds the remaining amount")
+	ErrNotEnoughLeft = errors.New("not enough units left")
+	ErrQuantityBelow = errors.New("quantity is below the reserved units")
+	ErrOrderMismatch = errors.New("order does not match the wishlist items")
+	ErrItemExists    = errors.New("item with this url exists")
+	ErrItemPledged   = errors.New("item has pledges")
+	ErrHiddenClaims  = errors.New("change conflicts with claims hidden by surprise mode")
+
+	ErrContributionNotFound = errors.New("contribution not found")
 )
+
+// Store is the full set of operations the service needs from a storage
+// backend. Handlers keep depending on their own narrow interfaces; Store
+// is what cmd/wishlist wires them with.
+type Store interface {
+	CreateList(ctx context.Context, name, alias string, uid int) (int, error)
+	GetAllLists(ctx context.Context, uid int) ([]entity.WishList, error)
+	WishListDel(ctx context.Context, wishListId, uid int) error
+	UpdateList(ctx context.Context, wishListId, uid int, upd entity.WishListUpdate) (entity.WishList, error)
+	GetList(ctx context.Context, alias string) (entity.WishList, []entity.GiftList, error)
+
+	CreateItem(ctx context.Context, wishlistId, uid int, gift entity.GiftCreate) (int, error)
+	GetByWishId(ctx context.Context, wishListId, uid int) ([]entity.GiftList, error)
+	DelItemById(ctx context.Context, itemId, uid int) error
+	UpdateItem(ctx context.Context, itemId, uid int, upd entity.GiftUpdate) (entity.GiftList, error)
+	ReorderItems(ctx context.Context, wishListId, uid int, giftIds []int) error
+	MoveItems(ctx context.Context, wishListId, uid int, giftIds []int) error
+	CopyItems(ctx context.Context, wishListId, uid int, giftIds []int) ([]int, error)
+	FillItem(ctx context.Context, itemId int, url string, meta entity.GiftMetadata) error
+	CountClick(ctx context.Context, itemId int) (string, error)
+
+	TrackedItems(ctx context.Context, checkedBefore time.Time, limit int) ([]entity.TrackedItem, error)
+	RecordPrice(ctx context.Context, itemId int, price *entity.Price, checkedAt time.Time) error
+	PriceHistory(ctx context.Context, itemId, uid int) ([]entity.PricePoint, error)
+
+	LinkItems(ctx context.Context, checkedBefore time.Time, limit int) ([]entity.LinkItem, error)
+	RecordLink(ctx context.Context, itemId int, url, status string, checkedAt time.Time) error
+
+	ReserveItem(ctx context.Context, alias string, itemId int, r entity.Reserver, quantity int) error
+	UnreserveItem(ctx context.Context, alias string, itemId int, r entity.Reserver) error
+	Pledge(ctx context.Context, alias string, itemId int, r entity.Reserver, amount int64) (int, error)
+	Withdraw(ctx context.Context, alias string, itemId, contributionId int, r entity.Reserver) error
+
+	CreateUser(ctx context.Context, login, passwordHash string) (int, error)
+	GetUserByLogin(ctx context.Context, login string) (entity.User, error)
+	GetOrCreateExternalUser(ctx context.Context, provider, subject, email string) (int, error)
+
+	CreateRefreshToken(ctx context.Context, uid int, familyId, tokenHash string, expiresAt time.Time) error
+	RotateRefreshToken(ctx context.Context, tokenHash, newHash string, expiresAt time.Time) (int, error)
+	RevokeRefreshFamily(ctx context.Context, uid int, tokenHash string) error
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpiredTokens(ctx context.Context) (int64, error)
+
+	CreateAPIToken(ctx context.Context, uid int, name, tokenHash string, scopes []string) (int, error)
+	GetAPITokens(ctx context.Context, uid int) ([]entity.APIToken, error)
+	RevokeAPIToken(ctx context.Context, tokenId, uid int) error
+	UseAPIToken(ctx context.Context, tokenHash string) (entity.APIToken, int, error)
+}